@@ -0,0 +1,107 @@
+// Package cluster coordinates the Galaxy nodes which share the same database.
+package cluster
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/galaxy-center/galaxy/config"
+	logger "github.com/galaxy-center/galaxy/log"
+	"github.com/galaxy-center/galaxy/models/lease"
+)
+
+// defaultLeaseTTL is used while config.ClusterConfig#LeaseTTL is absent.
+const defaultLeaseTTL = 15 * time.Second
+
+var (
+	log        = logger.Get()
+	clusterLog = log.WithField("prefix", "cluster")
+)
+
+// Elector campaigns for a lease, the node holding the lease is the leader.
+type Elector struct {
+	// leaseUntil the local unix nano time until which the lease is held,
+	// the leadership is lost after then even if renewing is blocked.
+	// Keeps it first for 64-bit atomic alignment.
+	leaseUntil int64
+
+	name   string
+	holder string
+	ttl    time.Duration
+}
+
+// NewElector returns an Elector of lease name for holder.
+func NewElector(name, holder string, ttl time.Duration) *Elector {
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+	return &Elector{name: name, holder: holder, ttl: ttl}
+}
+
+// LeaseTTL returns the configured lease ttl.
+func LeaseTTL() time.Duration {
+	if n := config.Global().Cluster.LeaseTTL; n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return defaultLeaseTTL
+}
+
+// Run campaigns and renews the lease every third of ttl until ctx is done,
+// then releases the lease.
+func (e *Elector) Run(ctx context.Context) {
+	e.campaign()
+
+	go func(ctx context.Context) {
+		ticker := time.NewTicker(e.ttl / 3)
+
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				e.resign()
+				return
+
+			case <-ticker.C:
+				e.campaign()
+			}
+		}
+	}(ctx)
+}
+
+// IsLeader returns true if the lease is held by current node.
+func (e *Elector) IsLeader() bool {
+	return time.Now().UnixNano() < atomic.LoadInt64(&e.leaseUntil)
+}
+
+// campaign acquires or renews the lease.
+func (e *Elector) campaign() {
+	wasLeader := e.IsLeader()
+	start := time.Now()
+
+	ok, err := lease.Acquire(e.name, e.holder, e.ttl)
+	if err != nil {
+		clusterLog.WithField("lease", e.name).Errorf("occurred exception when acquiring lease: %v", err)
+		return
+	}
+	if !ok {
+		atomic.StoreInt64(&e.leaseUntil, 0)
+		if wasLeader {
+			clusterLog.WithField("lease", e.name).Warn("Lost the leadership")
+		}
+		return
+	}
+	// counts from the time before acquiring, so the local view never outlives the lease.
+	atomic.StoreInt64(&e.leaseUntil, start.Add(e.ttl).UnixNano())
+	if !wasLeader {
+		clusterLog.WithField("lease", e.name).Info("Became the leader")
+	}
+}
+
+// resign releases the lease so that others can take over immediately.
+func (e *Elector) resign() {
+	atomic.StoreInt64(&e.leaseUntil, 0)
+	if err := lease.Release(e.name, e.holder); err != nil {
+		clusterLog.WithField("lease", e.name).Errorf("occurred exception when releasing lease: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/galaxy-center/galaxy/config"
+	db "github.com/galaxy-center/galaxy/lifecycle"
+	migrateProvider "github.com/galaxy-center/galaxy/migrate"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	config.SetTestMode(true)
+	db.Init()
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	os.Exit(code)
+}
+
+// leaders returns the electors which are the leader.
+func leaders(electors []*Elector) []*Elector {
+	var res []*Elector
+	for _, e := range electors {
+		if e.IsLeader() {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func TestElectorSingleLeader(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	var (
+		electors []*Elector
+		cancels  []context.CancelFunc
+	)
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		e := NewElector("test", fmt.Sprintf("node%d", i), 900*time.Millisecond)
+		e.Run(ctx)
+		electors = append(electors, e)
+		cancels = append(cancels, cancel)
+	}
+
+	for i := 0; i < 5; i++ {
+		assert.Len(t, leaders(electors), 1, "there should be exactly one leader")
+		time.Sleep(300 * time.Millisecond)
+	}
+
+	// the leader resigns, one of others takes over.
+	leader := leaders(electors)[0]
+	for i, e := range electors {
+		if e == leader {
+			cancels[i]()
+		}
+	}
+	time.Sleep(time.Second)
+	followers := leaders(electors)
+	assert.Len(t, followers, 1, "there should be exactly one leader after resigning")
+	assert.NotEqual(t, leader.holder, followers[0].holder, "leader should be changed")
+}
+
+func TestElectorFailover(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	// crashed holds the lease but never renews it.
+	crashed := NewElector("test", "crashed", time.Second)
+	crashed.campaign()
+	assert.True(t, crashed.IsLeader(), "crashed should be the leader at first")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	standby := NewElector("test", "standby", time.Second)
+	standby.Run(ctx)
+	assert.False(t, standby.IsLeader(), "standby should wait for the lease expired")
+
+	time.Sleep(1500 * time.Millisecond)
+	assert.False(t, crashed.IsLeader(), "crashed should lose the leadership after ttl")
+	assert.True(t, standby.IsLeader(), "standby should take over the expired lease")
+}
@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/galaxy-center/galaxy/models/node"
+)
+
+// Registry keeps the heartbeat of current node and watches the live nodes,
+// the work of MULTIPLE tasks is sharded by the position of nodes.
+type Registry struct {
+	id  string
+	ttl time.Duration
+
+	mu sync.RWMutex
+	// nodes the live node ids ordered by id.
+	nodes []string
+}
+
+// NewRegistry returns a Registry of node id, the node is considered dead if
+// there is no heartbeat in ttl.
+func NewRegistry(id string, ttl time.Duration) *Registry {
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+	return &Registry{id: id, ttl: ttl}
+}
+
+// Run keeps heartbeat and refreshes the live nodes every third of ttl until
+// ctx is done, then unregisters current node.
+func (r *Registry) Run(ctx context.Context) {
+	r.refresh()
+
+	go func(ctx context.Context) {
+		ticker := time.NewTicker(r.ttl / 3)
+
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				if err := node.Delete(r.id); err != nil {
+					clusterLog.WithField("node", r.id).Errorf("occurred exception when unregistering node: %v", err)
+				}
+				return
+
+			case <-ticker.C:
+				r.refresh()
+			}
+		}
+	}(ctx)
+}
+
+// Shard returns the index of current node and the total of live nodes,
+// returns false if current node is not alive.
+func (r *Registry) Shard() (index, total int, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i, id := range r.nodes {
+		if id == r.id {
+			return i, len(r.nodes), true
+		}
+	}
+	return 0, len(r.nodes), false
+}
+
+// Nodes returns the live node ids.
+func (r *Registry) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.nodes...)
+}
+
+// refresh heartbeats and reloads the live nodes, the shards are changed
+// while any node joins or leaves.
+func (r *Registry) refresh() {
+	if err := node.Heartbeat(r.id); err != nil {
+		clusterLog.WithField("node", r.id).Errorf("occurred exception when heartbeating: %v", err)
+		r.setNodes(nil)
+		return
+	}
+	alive, err := node.GetAlive(uint64(time.Now().Add(-r.ttl).UnixNano()))
+	if err != nil {
+		clusterLog.WithField("node", r.id).Errorf("occurred exception when loading nodes: %v", err)
+		return
+	}
+	ids := make([]string, 0, len(alive))
+	for _, n := range alive {
+		ids = append(ids, n.ID)
+	}
+	r.setNodes(ids)
+}
+
+func (r *Registry) setNodes(ids []string) {
+	r.mu.Lock()
+	changed := strings.Join(r.nodes, ",") != strings.Join(ids, ",")
+	r.nodes = ids
+	r.mu.Unlock()
+
+	if changed {
+		clusterLog.WithField("node", r.id).Infof("Resharded by live nodes: %v", ids)
+	}
+}
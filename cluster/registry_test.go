@@ -0,0 +1,48 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	migrateProvider "github.com/galaxy-center/galaxy/migrate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryShard(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	defer cancelA()
+	a := NewRegistry("nodeA", 900*time.Millisecond)
+	a.Run(ctxA)
+
+	index, total, ok := a.Shard()
+	assert.True(t, ok, "nodeA should be alive")
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 1, total)
+
+	// nodeB joins.
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+	b := NewRegistry("nodeB", 900*time.Millisecond)
+	b.Run(ctxB)
+	time.Sleep(time.Second)
+
+	index, total, _ = a.Shard()
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 2, total, "nodeA should be resharded after nodeB joined")
+	index, total, _ = b.Shard()
+	assert.Equal(t, 1, index)
+	assert.Equal(t, 2, total)
+
+	// nodeA leaves.
+	cancelA()
+	time.Sleep(time.Second)
+
+	index, total, _ = b.Shard()
+	assert.Equal(t, 0, index, "nodeB should be resharded after nodeA left")
+	assert.Equal(t, 1, total)
+}
@@ -98,6 +98,38 @@ type LivenessCheckConfig struct {
 	CheckDuration time.Duration `json:"check_duration"`
 }
 
+// ClusterConfig coordination between nodes.
+type ClusterConfig struct {
+	// LeaseTTL unit is seconds, default is 15.
+	LeaseTTL int `json:"lease_ttl"`
+}
+
+// ExecutorConfig the executors of tasks.
+type ExecutorConfig struct {
+	// DisableCommand forbids the COMMAND executor, which runs local commands
+	// on the node, e.g. in locked-down deployments.
+	DisableCommand bool `json:"disable_command"`
+	// Workers the max number of runs executing at once on the node, default
+	// is 100.
+	Workers int `json:"workers"`
+}
+
+// KafkaConfig the brokers which the KAFKA executor publishes to.
+type KafkaConfig struct {
+	// Brokers e.g. localhost:9092, the KAFKA executor is unavailable if
+	// absent.
+	Brokers []string `json:"brokers"`
+	// Timeout of the requests to brokers, unit is seconds, default is 10.
+	Timeout int `json:"timeout"`
+}
+
+// DelayConfig the one-shot tasks of delay messages.
+type DelayConfig struct {
+	// RetentionDays how long the delivered tasks are kept after deleted
+	// softly, their dedupe keys are taken until purged. Default is 7.
+	RetentionDays int `json:"retention_days"`
+}
+
 // Config global configs.
 type Config struct {
 	// OriginalPath is the path to the config file that was read. If
@@ -111,6 +143,14 @@ type Config struct {
 
 	MySQLConfig DBConfig `json:"mysql_config"`
 
+	Cluster ClusterConfig `json:"cluster"`
+
+	Executor ExecutorConfig `json:"executor"`
+
+	Kafka KafkaConfig `json:"kafka"`
+
+	Delay DelayConfig `json:"delay"`
+
 	App App `json:"app"`
 }
 
@@ -0,0 +1,369 @@
+// Package cron parses the cron expressions of tasks and computes their next
+// fire time.
+//
+// Supported formats:
+//   - standard 5 fields: minute hour day-of-month month day-of-week
+//   - 6 fields with seconds: second minute hour day-of-month month day-of-week
+//   - macros: @yearly(@annually), @monthly, @weekly, @daily(@midnight), @hourly
+//
+// Every field accepts `*`, `?`(day fields only), lists `a,b`, ranges `a-b`
+// and steps `*/n` or `a-b/n`, months and weekdays accept names, e.g. JAN, MON.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule describes a parsed cron expression, every field is a bit set of
+// the matched values.
+type Schedule struct {
+	Second, Minute, Hour, Dom, Month, Dow uint64
+}
+
+// bounds of a field.
+type bounds struct {
+	name     string
+	min, max uint
+	names    map[string]uint
+	// question accepts `?` as `*`, it's only for the day fields.
+	question bool
+}
+
+var (
+	seconds = bounds{name: "second", min: 0, max: 59}
+	minutes = bounds{name: "minute", min: 0, max: 59}
+	hours   = bounds{name: "hour", min: 0, max: 23}
+	dom     = bounds{name: "day-of-month", min: 1, max: 31, question: true}
+	months  = bounds{name: "month", min: 1, max: 12, names: map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// day-of-week accepts 7 as Sunday too.
+	dow = bounds{name: "day-of-week", min: 0, max: 7, question: true, names: map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	macros = map[string]string{
+		"@yearly":   "0 0 0 1 1 *",
+		"@annually": "0 0 0 1 1 *",
+		"@monthly":  "0 0 0 1 * *",
+		"@weekly":   "0 0 0 * * 0",
+		"@daily":    "0 0 0 * * *",
+		"@midnight": "0 0 0 * * *",
+		"@hourly":   "0 0 * * * *",
+	}
+)
+
+// starBit marks the field was `*` or `?`.
+const starBit = 1 << 63
+
+// Parse returns the schedule of the spec, otherwise returns the reason why
+// spec is invalid.
+func Parse(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty cron expression")
+	}
+	if strings.HasPrefix(spec, "@") {
+		expanded, ok := macros[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("unrecognized cron macro %s", spec)
+		}
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("expected 5 or 6 fields, found %d: %s", len(fields), spec)
+	}
+
+	var (
+		s   Schedule
+		err error
+	)
+	for i, f := range []struct {
+		bits *uint64
+		b    bounds
+	}{
+		{&s.Second, seconds},
+		{&s.Minute, minutes},
+		{&s.Hour, hours},
+		{&s.Dom, dom},
+		{&s.Month, months},
+		{&s.Dow, dow},
+	} {
+		if *f.bits, err = parseField(fields[i], f.b); err != nil {
+			return nil, err
+		}
+	}
+	// 7 is an alias of Sunday.
+	if s.Dow&(1<<7) > 0 {
+		s.Dow = s.Dow&^(1<<7) | 1
+	}
+	return &s, nil
+}
+
+// MustParse is like Parse but panics if the spec is invalid.
+func MustParse(spec string) *Schedule {
+	s, err := Parse(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Validate returns nil if spec is a valid cron expression.
+func Validate(spec string) error {
+	_, err := Parse(spec)
+	return err
+}
+
+// parseField returns the bit set of a comma-separated field.
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, expr := range strings.Split(field, ",") {
+		bit, err := parseRange(expr, b)
+		if err != nil {
+			return 0, err
+		}
+		bits |= bit
+	}
+	return bits, nil
+}
+
+// parseRange returns the bit set of a single expression, e.g. `*`, `1-5/2`.
+func parseRange(expr string, b bounds) (uint64, error) {
+	var (
+		start, end, step uint
+		extra            uint64
+		err              error
+	)
+	rangeAndStep := strings.Split(expr, "/")
+	lowAndHigh := strings.Split(rangeAndStep[0], "-")
+	if len(rangeAndStep) > 2 || len(lowAndHigh) > 2 {
+		return 0, fmt.Errorf("invalid %s expression %q", b.name, expr)
+	}
+
+	if lowAndHigh[0] == "*" || (lowAndHigh[0] == "?" && b.question) {
+		if len(lowAndHigh) > 1 {
+			return 0, fmt.Errorf("invalid %s expression %q", b.name, expr)
+		}
+		start, end = b.min, b.max
+		extra = starBit
+	} else {
+		if start, err = parseValue(lowAndHigh[0], b); err != nil {
+			return 0, err
+		}
+		end = start
+		if len(lowAndHigh) == 2 {
+			if end, err = parseValue(lowAndHigh[1], b); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	step = 1
+	if len(rangeAndStep) == 2 {
+		if step, err = parseUint(rangeAndStep[1], b); err != nil {
+			return 0, err
+		}
+		if step == 0 {
+			return 0, fmt.Errorf("step of %s should be positive: %q", b.name, expr)
+		}
+		// `a/n` means from a to the max.
+		if len(lowAndHigh) == 1 && extra == 0 {
+			end = b.max
+		}
+		extra = 0
+	}
+
+	if start > end {
+		return 0, fmt.Errorf("beginning of %s range %d beyond end %d", b.name, start, end)
+	}
+
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << i
+	}
+	return bits | extra, nil
+}
+
+// parseValue parses a number or a name of the field.
+func parseValue(v string, b bounds) (uint, error) {
+	if b.names != nil {
+		if n, ok := b.names[strings.ToLower(v)]; ok {
+			return n, nil
+		}
+	}
+	n, err := parseUint(v, b)
+	if err != nil {
+		return 0, err
+	}
+	if n < b.min || n > b.max {
+		return 0, fmt.Errorf("%s value %d out of range [%d, %d]", b.name, n, b.min, b.max)
+	}
+	return n, nil
+}
+
+func parseUint(v string, b bounds) (uint, error) {
+	n, err := strconv.ParseUint(v, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q", b.name, v)
+	}
+	return uint(n), nil
+}
+
+// Next returns the next fire time strictly after t in the location of t.
+// A zero time is returned if no time can be found within five years.
+//
+// The schedule is evaluated on the wall clock of the location, every matched
+// wall time fires once even if the clock jumps for daylight saving time:
+//   - gap (spring forward): the wall times skipped by the gap fire once at
+//     the end of the gap, together with the one at the end if matched,
+//     e.g. 02:30 fires at 03:00 when the clock jumps from 02:00 to 03:00.
+//   - overlap (fall back): the repeated wall times only fire at their first
+//     occurrence, e.g. 01:30 fires once when 01:00-02:00 is repeated.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	wall := toWall(t)
+	for {
+		wall = s.nextWall(wall)
+		if wall.IsZero() {
+			return time.Time{}
+		}
+		// the wall times in the second pass of an overlap have fired.
+		if next := fromWall(wall, loc); next.After(t) {
+			return next
+		}
+	}
+}
+
+// dstProbe how far the offsets are probed around a wall time to find the
+// daylight saving time transition.
+const dstProbe = 24 * time.Hour
+
+// toWall returns the wall clock of t in UTC, which has no transition.
+func toWall(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// fromWall returns the first time in loc whose wall clock is wall, or the end
+// of the gap if wall is skipped by the gap.
+func fromWall(wall time.Time, loc *time.Location) time.Time {
+	_, before := wall.Add(-dstProbe).In(loc).Zone()
+	_, after := wall.Add(dstProbe).In(loc).Zone()
+	// the larger offset comes first in the overlap.
+	offsets := []int{before, after}
+	if after > before {
+		offsets = []int{after, before}
+	}
+	for _, offset := range offsets {
+		t := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		if toWall(t).Equal(wall) {
+			return t
+		}
+	}
+
+	// skipped by the gap, searches the transition where the offset changes.
+	lo, hi := wall.Add(-dstProbe), wall.Add(dstProbe)
+	for hi.Sub(lo) > time.Second {
+		mid := lo.Add(hi.Sub(lo) / 2)
+		if _, offset := mid.In(loc).Zone(); offset == after {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi.Truncate(time.Second).In(loc)
+}
+
+// nextWall returns the next matched wall time strictly after t, t should be
+// in UTC so that no time is skipped or repeated.
+func (s *Schedule) nextWall(t time.Time) time.Time {
+	loc := t.Location()
+
+	// rounds up to the next whole second.
+	t = t.Add(time.Second - time.Duration(t.Nanosecond())*time.Nanosecond)
+	added := false
+	yearLimit := t.Year() + 5
+
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for 1<<uint(t.Month())&s.Month == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+
+	for !s.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+
+	for 1<<uint(t.Hour())&s.Hour == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+
+	for 1<<uint(t.Minute())&s.Minute == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+
+	for 1<<uint(t.Second())&s.Second == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+
+	return t
+}
+
+// dayMatches returns true if t matches both day-of-month and day-of-week,
+// or either of them when both are restricted, like vixie cron does.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := 1<<uint(t.Day())&s.Dom > 0
+	dowMatch := 1<<uint(t.Weekday())&s.Dow > 0
+	if s.Dom&starBit > 0 || s.Dow&starBit > 0 {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,166 @@
+package cron
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*-5 * * * *",
+		"a * * * *",
+		"@every",
+		"? * * * *",
+		"0 ? * * *",
+		"* * * ? *",
+	} {
+		_, err := Parse(spec)
+		assert.Error(t, err, "spec %q should be invalid", spec)
+	}
+}
+
+func TestNext(t *testing.T) {
+	from := time.Date(2020, time.December, 31, 23, 59, 30, 500, time.UTC)
+	for _, c := range []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"* * * * * *", time.Date(2020, time.December, 31, 23, 59, 31, 0, time.UTC)},
+		{"*/15 * * * * *", time.Date(2020, time.December, 31, 23, 59, 45, 0, time.UTC)},
+		{"30 8 * * *", time.Date(2021, time.January, 1, 8, 30, 0, 0, time.UTC)},
+		{"0 9 * * MON-FRI", time.Date(2021, time.January, 1, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * sat,sun", time.Date(2021, time.January, 2, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, time.January, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 15 * 1", time.Date(2021, time.January, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1 JAN-MAR/2 ?", time.Date(2021, time.January, 1, 12, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2021, time.January, 3, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		s, err := Parse(c.spec)
+		assert.Nil(t, err, "spec %q should be valid", c.spec)
+		assert.Equal(t, c.next, s.Next(from), "next of %q error", c.spec)
+	}
+}
+
+func TestNextIsStrictlyAfter(t *testing.T) {
+	s := MustParse("0 * * * * *")
+	from := time.Date(2021, time.March, 1, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, from.Add(time.Minute), s.Next(from))
+}
+
+func TestNextNotFound(t *testing.T) {
+	s := MustParse("0 0 30 2 *")
+	assert.True(t, s.Next(time.Now()).IsZero(), "February 30th should never fire")
+}
+
+func TestNextInLocation(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	assert.Nil(t, err)
+	from := time.Date(2021, time.January, 1, 7, 0, 0, 0, time.UTC)
+
+	next := MustParse("30 8 * * *").Next(from.In(loc))
+	assert.Equal(t, loc, next.Location())
+	assert.True(t, time.Date(2021, time.January, 2, 0, 30, 0, 0, time.UTC).Equal(next), "08:30 in Shanghai is 00:30 UTC, got %s", next)
+}
+
+func TestNextSpringForward(t *testing.T) {
+	// New York jumps from 02:00 EST to 03:00 EDT on 2021-03-14.
+	loc, err := time.LoadLocation("America/New_York")
+	assert.Nil(t, err)
+	date := func(day, hour, min int) time.Time {
+		return time.Date(2021, time.March, day, hour, min, 0, 0, loc)
+	}
+	edt := time.FixedZone("EDT", -4*3600)
+
+	cases := []struct {
+		spec string
+		from time.Time
+		next []time.Time
+	}{
+		// the skipped 02:30 fires at the end of the gap.
+		{"30 2 * * *", date(14, 0, 0), []time.Time{
+			time.Date(2021, time.March, 14, 3, 0, 0, 0, edt),
+			time.Date(2021, time.March, 15, 2, 30, 0, 0, edt),
+		}},
+		// 02:00, 02:30 and 03:00 fire once at 03:00.
+		{"*/30 * * * *", date(14, 1, 30), []time.Time{
+			time.Date(2021, time.March, 14, 3, 0, 0, 0, edt),
+			time.Date(2021, time.March, 14, 3, 30, 0, 0, edt),
+		}},
+		{"0 * * * *", date(14, 1, 0), []time.Time{
+			time.Date(2021, time.March, 14, 3, 0, 0, 0, edt),
+			time.Date(2021, time.March, 14, 4, 0, 0, 0, edt),
+		}},
+		{"0 3 * * *", date(14, 0, 0), []time.Time{
+			time.Date(2021, time.March, 14, 3, 0, 0, 0, edt),
+			time.Date(2021, time.March, 15, 3, 0, 0, 0, edt),
+		}},
+	}
+	for _, c := range cases {
+		s := MustParse(c.spec)
+		from := c.from
+		for _, want := range c.next {
+			next := s.Next(from)
+			assert.True(t, want.Equal(next), "next of %q from %s should be %s, got %s", c.spec, from, want, next)
+			from = next
+		}
+	}
+}
+
+func TestNextFallBack(t *testing.T) {
+	// New York repeats 01:00-02:00 on 2021-11-07, from EDT to EST.
+	loc, err := time.LoadLocation("America/New_York")
+	assert.Nil(t, err)
+	edt := time.FixedZone("EDT", -4*3600)
+	est := time.FixedZone("EST", -5*3600)
+
+	cases := []struct {
+		spec string
+		from time.Time
+		next []time.Time
+	}{
+		// the repeated 01:30 only fires at its first occurrence.
+		{"30 1 * * *", time.Date(2021, time.November, 7, 0, 0, 0, 0, edt), []time.Time{
+			time.Date(2021, time.November, 7, 1, 30, 0, 0, edt),
+			time.Date(2021, time.November, 8, 1, 30, 0, 0, est),
+		}},
+		{"*/30 * * * *", time.Date(2021, time.November, 7, 1, 0, 0, 0, edt), []time.Time{
+			time.Date(2021, time.November, 7, 1, 30, 0, 0, edt),
+			time.Date(2021, time.November, 7, 2, 0, 0, 0, est),
+		}},
+		// starts from the second pass, the repeated wall times have fired.
+		{"*/30 * * * *", time.Date(2021, time.November, 7, 1, 10, 0, 0, est), []time.Time{
+			time.Date(2021, time.November, 7, 2, 0, 0, 0, est),
+		}},
+		{"0 2 * * *", time.Date(2021, time.November, 7, 1, 0, 0, 0, edt), []time.Time{
+			time.Date(2021, time.November, 7, 2, 0, 0, 0, est),
+		}},
+	}
+	for _, c := range cases {
+		s := MustParse(c.spec)
+		from := c.from.In(loc)
+		for _, want := range c.next {
+			next := s.Next(from)
+			assert.True(t, want.Equal(next), "next of %q from %s should be %s, got %s", c.spec, from, want, next)
+			from = next
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/galaxy-center/galaxy/config"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+)
+
+const (
+	// ShardIndexEnv the environment variable of shard index for COMMAND executor.
+	ShardIndexEnv = "GALAXY_SHARD_INDEX"
+	// ShardTotalEnv the environment variable of shard total for COMMAND executor.
+	ShardTotalEnv = "GALAXY_SHARD_TOTAL"
+)
+
+// CommandContent describes TaskConfig#Content of COMMAND executor. The command
+// only inherits PATH of Galaxy, the other environment variables should be set
+// by Env explicitly.
+type CommandContent struct {
+	// Args the argv, Args[0] is the program, it's not run by shell.
+	Args []string          `json:"args"`
+	Env  map[string]string `json:"env,omitempty"`
+	// Dir the working directory, default is the one of Galaxy.
+	Dir string `json:"dir,omitempty"`
+}
+
+// executeCommand runs the command described by conf, the task is finished if
+// the command exits with 0, the whole process group is killed once ctx is done.
+func executeCommand(ctx context.Context, conf *taskconfig.TaskConfig) Result {
+	if config.Global().Executor.DisableCommand {
+		return Failed(0, "COMMAND executor is disabled")
+	}
+	content, err := parseCommandContent(conf)
+	if err != nil {
+		return Failed(0, err.Error())
+	}
+
+	stdout := &cappedBuffer{max: maxResponseSize}
+	stderr := &cappedBuffer{max: maxResponseSize}
+	cmd := exec.Command(content.Args[0], content.Args[1:]...)
+	cmd.Dir = content.Dir
+	cmd.Env = commandEnv(ctx, content.Env)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return Failed(0, fmt.Sprintf("start command failed: %v", err))
+	}
+
+	waited := make(chan error, 1)
+	go func() {
+		waited <- cmd.Wait()
+	}()
+	select {
+	case err = <-waited:
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		err = <-waited
+	}
+
+	message := fmt.Sprintf("stdout: %s\nstderr: %s", stdout, stderr)
+	if err == nil {
+		return Finished(0, "exit 0\n"+message)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return Failed(exitErr.ExitCode(), fmt.Sprintf("exit %d\n%s", exitErr.ExitCode(), message))
+	}
+	// killed by signal or failed to wait, there is no exit code.
+	return Failed(0, fmt.Sprintf("%v\n%s", err, message))
+}
+
+// parseCommandContent returns the content of TaskConfig.
+func parseCommandContent(conf *taskconfig.TaskConfig) (*CommandContent, error) {
+	if conf == nil {
+		return nil, fmt.Errorf("task config not found")
+	}
+	var content CommandContent
+	if err := json.Unmarshal(conf.Content, &content); err != nil {
+		return nil, fmt.Errorf("invalid command content: %v", err)
+	}
+	if len(content.Args) == 0 || content.Args[0] == "" {
+		return nil, fmt.Errorf("args of command content are required")
+	}
+	return &content, nil
+}
+
+// commandEnv returns the environment variables of command.
+func commandEnv(ctx context.Context, env map[string]string) []string {
+	vars := make([]string, 0, len(env)+3)
+	if path, ok := os.LookupEnv("PATH"); ok {
+		vars = append(vars, "PATH="+path)
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		vars = append(vars, k+"="+env[k])
+	}
+	if shard, ok := ShardFrom(ctx); ok {
+		vars = append(vars,
+			ShardIndexEnv+"="+strconv.Itoa(shard.Index),
+			ShardTotalEnv+"="+strconv.Itoa(shard.Total))
+	}
+	return vars
+}
+
+// cappedBuffer keeps the first max bytes written which are cut at a rune
+// boundary, the rest are discarded.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if n := b.max - b.buf.Len(); n < len(p) {
+		b.truncated = true
+		for n > 0 && !utf8.RuneStart(p[n]) {
+			n--
+		}
+		if n > 0 {
+			b.buf.Write(p[:n])
+		}
+		// nothing more is kept, even if it fits.
+		b.max = b.buf.Len()
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// String returns the kept bytes with "..." if some are discarded, the invalid
+// UTF-8 is replaced, e.g. the output of binaries.
+func (b *cappedBuffer) String() string {
+	s := strings.ToValidUTF8(b.buf.String(), string(utf8.RuneError))
+	if b.truncated {
+		return s + "..."
+	}
+	return s
+}
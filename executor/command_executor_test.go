@@ -0,0 +1,90 @@
+package executor
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/galaxy-center/galaxy/config"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+func TestExecuteCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not found")
+	}
+	conf := &taskconfig.TaskConfig{
+		Content: datatypes.JSON(`{"args":["sh","-c","echo $GREETING; pwd; echo $GALAXY_SHARD_TOTAL >&2"],"env":{"GREETING":"hello"},"dir":"/"}`),
+	}
+	ctx := WithShard(context.Background(), Shard{Index: 0, Total: 2})
+	res := Execute(ctx, &task.Task{Executor: task.COMMAND, Timeout: 3}, conf)
+	assert.EqualValues(t, schedulingrecord.FINISHED, res.Status, res.Message)
+	assert.Equal(t, "exit 0\nstdout: hello\n/\n\nstderr: 2\n", res.Message)
+
+	conf.Content = datatypes.JSON(`{"args":["sh","-c","head -c 4096 /dev/zero | tr '\\0' x; exit 3"]}`)
+	res = Execute(context.Background(), &task.Task{Executor: task.COMMAND, Timeout: 3}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, 3, res.Code)
+	assert.True(t, strings.HasPrefix(res.Message, "exit 3\nstdout: xxx"), res.Message)
+	assert.Equal(t, len("exit 3\nstdout: ")+maxResponseSize+len("...\nstderr: "), len(res.Message), "output should be truncated")
+}
+
+func TestExecuteCommandTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not found")
+	}
+	// the child sleep holds the output, it's killed with the process group.
+	conf := &taskconfig.TaskConfig{Content: datatypes.JSON(`{"args":["sh","-c","sleep 10 & wait"]}`)}
+	start := time.Now()
+	res := executeCommand(timeoutContext(t, time.Second), conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, 0, res.Code)
+	assert.True(t, strings.HasPrefix(res.Message, "signal: killed"), res.Message)
+	assert.True(t, time.Since(start) < 2*time.Second, "process group should be killed")
+}
+
+func TestExecuteCommandInvalid(t *testing.T) {
+	conf := &taskconfig.TaskConfig{Content: datatypes.JSON(`{"args":[]}`)}
+	res := Execute(context.Background(), &task.Task{Executor: task.COMMAND}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, "args of command content are required", res.Message)
+
+	conf = &taskconfig.TaskConfig{Content: datatypes.JSON(`{"args":["/not/exist"]}`)}
+	res = Execute(context.Background(), &task.Task{Executor: task.COMMAND}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.True(t, strings.HasPrefix(res.Message, "start command failed"), res.Message)
+
+	global := config.Global()
+	disabled := global
+	disabled.Executor.DisableCommand = true
+	config.SetGlobal(disabled)
+	defer config.SetGlobal(global)
+
+	res = Execute(context.Background(), &task.Task{Executor: task.COMMAND}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, "COMMAND executor is disabled", res.Message)
+}
+
+func timeoutContext(t *testing.T, d time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestCappedBuffer(t *testing.T) {
+	b := &cappedBuffer{max: 5}
+	b.Write([]byte("ab"))
+	b.Write([]byte("中文"))
+	b.Write([]byte("c"))
+	assert.Equal(t, "ab中...", b.String(), "should be cut at a rune boundary")
+
+	b = &cappedBuffer{max: 10}
+	b.Write([]byte("a\xffb"))
+	assert.Equal(t, "a�b", b.String(), "invalid UTF-8 should be replaced")
+}
@@ -0,0 +1,92 @@
+// Package executor performs the tasks which are handed over by the scheduler.
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	logger "github.com/galaxy-center/galaxy/log"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+)
+
+var (
+	log         = logger.Get()
+	executorLog = log.WithField("prefix", "executor")
+)
+
+// Result of an execution.
+type Result struct {
+	// Status FINISHED, FAILED or TIMEOUT.
+	Status schedulingrecord.Status
+	// Message will be stored to the scheduling record.
+	Message string
+	// Code the status code responded by the remote, e.g. http status code,
+	// 0 means no response.
+	Code int
+}
+
+// Finished returns a FINISHED result.
+func Finished(code int, message string) Result {
+	return Result{Status: schedulingrecord.FINISHED, Code: code, Message: message}
+}
+
+// Failed returns a FAILED result.
+func Failed(code int, message string) Result {
+	return Result{Status: schedulingrecord.FAILED, Code: code, Message: message}
+}
+
+// Execute performs the task with its config within Task#Timeout, the result
+// is TIMEOUT once the timeout exceeded even if the executor doesn't return.
+func Execute(ctx context.Context, t *task.Task, conf *taskconfig.TaskConfig) Result {
+	if t.Timeout <= 0 {
+		return execute(ctx, t, conf)
+	}
+	timeout := time.Duration(t.Timeout) * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan Result, 1)
+	go func() {
+		done <- execute(ctx, t, conf)
+	}()
+	select {
+	case res := <-done:
+		if res.Status == schedulingrecord.FINISHED || ctx.Err() != context.DeadlineExceeded {
+			return res
+		}
+		return Result{Status: schedulingrecord.TIMEOUT, Code: res.Code, Message: fmt.Sprintf("timeout after %s: %s", timeout, res.Message)}
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return Result{Status: schedulingrecord.TIMEOUT, Message: fmt.Sprintf("timeout after %s", timeout)}
+		}
+		return Failed(0, ctx.Err().Error())
+	}
+}
+
+// execute dispatches the task to its registered executor.
+func execute(ctx context.Context, t *task.Task, conf *taskconfig.TaskConfig) Result {
+	e, ok := Lookup(t.Executor)
+	if !ok {
+		executorLog.WithField("task", t.ID).Warnf("executor %s is not registered", t.Executor)
+		return Failed(0, fmt.Sprintf("executor %s is not registered", t.Executor))
+	}
+	return e.Execute(ctx, t, conf)
+}
+
+// truncate keeps at most max bytes of message which is cut at a rune
+// boundary, "..." is appended if it's cut. The invalid UTF-8 is replaced, so
+// that the message can be stored as utf8mb4.
+func truncate(message string, max int) string {
+	if len(message) > max {
+		for max > 0 && !utf8.RuneStart(message[max]) {
+			max--
+		}
+		message = message[:max] + "..."
+	}
+	return strings.ToValidUTF8(message, string(utf8.RuneError))
+}
@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+)
+
+// maxResponseSize the max size of response body kept in the record message.
+const maxResponseSize = 1024
+
+var httpClient = &http.Client{}
+
+// HTTPContent describes TaskConfig#Content of HTTP executor, the request
+// headers come from TaskConfig#Headers.
+type HTTPContent struct {
+	// Method default is GET, or POST while body is present.
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Body will be sent as it is if it's a JSON string, otherwise be sent as JSON.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// executeHTTP performs the request described by conf.
+func executeHTTP(ctx context.Context, conf *taskconfig.TaskConfig) Result {
+	req, err := buildRequest(ctx, conf)
+	if err != nil {
+		return Failed(0, err.Error())
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return Failed(0, err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return Failed(resp.StatusCode, fmt.Sprintf("status %d: read body failed: %v", resp.StatusCode, err))
+	}
+	message := fmt.Sprintf("status %d: %s", resp.StatusCode, truncate(string(body), maxResponseSize))
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return Failed(resp.StatusCode, message)
+	}
+	return Finished(resp.StatusCode, message)
+}
+
+// buildRequest returns the request from TaskConfig.
+func buildRequest(ctx context.Context, conf *taskconfig.TaskConfig) (*http.Request, error) {
+	if conf == nil {
+		return nil, fmt.Errorf("task config not found")
+	}
+	var content HTTPContent
+	if err := json.Unmarshal(conf.Content, &content); err != nil {
+		return nil, fmt.Errorf("invalid http content: %v", err)
+	}
+	if content.URL == "" {
+		return nil, fmt.Errorf("url of http content is required")
+	}
+
+	var (
+		body        io.Reader
+		contentType string
+	)
+	if len(content.Body) > 0 && string(content.Body) != "null" {
+		var raw string
+		if err := json.Unmarshal(content.Body, &raw); err == nil {
+			body = bytes.NewBufferString(raw)
+		} else {
+			body = bytes.NewReader(content.Body)
+			contentType = "application/json"
+		}
+		if content.Method == "" {
+			content.Method = http.MethodPost
+		}
+	}
+	if content.Method == "" {
+		content.Method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, content.Method, content.URL, body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid http request: %v", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if len(conf.Headers) > 0 {
+		var headers map[string]interface{}
+		if err := json.Unmarshal(conf.Headers, &headers); err != nil {
+			return nil, fmt.Errorf("invalid http headers: %v", err)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, fmt.Sprint(v))
+		}
+	}
+	if shard, ok := ShardFrom(ctx); ok {
+		for k, v := range shard.headers() {
+			req.Header.Set(k, v)
+		}
+	}
+	return req, nil
+}
@@ -0,0 +1,128 @@
+package executor
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/galaxy-center/galaxy/config"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+func init() {
+	config.SetTestMode(true)
+}
+
+func TestExecuteHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "secret", r.Header.Get("X-Auth"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.JSONEq(t, `{"id":1}`, string(body))
+		w.Write([]byte("done"))
+	}))
+	defer server.Close()
+
+	conf := &taskconfig.TaskConfig{
+		Headers: datatypes.JSON(`{"X-Auth":"secret"}`),
+		Content: datatypes.JSON(`{"url":"` + server.URL + `","body":{"id":1}}`),
+	}
+	res := Execute(context.Background(), &task.Task{Executor: task.HTTP, Timeout: 3}, conf)
+	assert.EqualValues(t, schedulingrecord.FINISHED, res.Status)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "status 200: done", res.Message)
+}
+
+func TestExecuteHTTPFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(strings.Repeat("x", maxResponseSize*2)))
+	}))
+	defer server.Close()
+
+	conf := &taskconfig.TaskConfig{
+		Content: datatypes.JSON(`{"method":"PUT","url":"` + server.URL + `","body":"raw"}`),
+	}
+	res := Execute(context.Background(), &task.Task{Executor: task.HTTP, Timeout: 3}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, http.StatusBadGateway, res.Code)
+	assert.True(t, strings.HasPrefix(res.Message, "status 502: xxx"), "message error")
+	assert.Equal(t, len("status 502: ")+maxResponseSize+len("..."), len(res.Message), "message should be truncated")
+}
+
+func TestExecuteHTTPTruncatedAtRune(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("中", maxResponseSize)))
+	}))
+	defer server.Close()
+
+	conf := &taskconfig.TaskConfig{
+		Content: datatypes.JSON(`{"url":"` + server.URL + `"}`),
+	}
+	res := Execute(context.Background(), &task.Task{Executor: task.HTTP, Timeout: 3}, conf)
+	assert.EqualValues(t, schedulingrecord.FINISHED, res.Status)
+	assert.True(t, utf8.ValidString(res.Message), "message should be valid UTF-8")
+	assert.True(t, strings.HasSuffix(res.Message, "中..."), "message should be cut at a rune boundary")
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "ab", truncate("ab", 2))
+	assert.Equal(t, "a...", truncate("a中", 2))
+	assert.Equal(t, "...", truncate("中", 1))
+	assert.Equal(t, "a\uFFFDb", truncate("a\xffb", 10), "invalid UTF-8 should be replaced")
+}
+
+func TestExecuteHTTPTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(3 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	conf := &taskconfig.TaskConfig{
+		Content: datatypes.JSON(`{"url":"` + server.URL + `"}`),
+	}
+	start := time.Now()
+	res := Execute(context.Background(), &task.Task{Executor: task.HTTP, Timeout: 1}, conf)
+	assert.EqualValues(t, schedulingrecord.TIMEOUT, res.Status)
+	assert.Equal(t, 0, res.Code)
+	assert.True(t, time.Since(start) < 2*time.Second, "timeout should be honored")
+}
+
+func TestExecuteHTTPInvalidConfig(t *testing.T) {
+	res := Execute(context.Background(), &task.Task{Executor: task.HTTP}, nil)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, "task config not found", res.Message)
+
+	conf := &taskconfig.TaskConfig{Content: datatypes.JSON(`{"method":"GET"}`)}
+	res = Execute(context.Background(), &task.Task{Executor: task.HTTP}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+}
+
+func TestExecuteHTTPShard(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "1", r.Header.Get(ShardIndexHeader))
+		assert.Equal(t, "3", r.Header.Get(ShardTotalHeader))
+	}))
+	defer server.Close()
+
+	conf := &taskconfig.TaskConfig{
+		Content: datatypes.JSON(`{"url":"` + server.URL + `"}`),
+	}
+	ctx := WithShard(context.Background(), Shard{Index: 1, Total: 3})
+	res := Execute(ctx, &task.Task{Executor: task.HTTP, SchedulingCategory: task.MULTIPLE}, conf)
+	assert.EqualValues(t, schedulingrecord.FINISHED, res.Status)
+}
@@ -0,0 +1,125 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+)
+
+// KafkaMessage is the message published by KAFKA executor.
+type KafkaMessage struct {
+	Topic   string
+	Key     string
+	Value   []byte
+	Headers map[string]string
+}
+
+// KafkaDelivery is the acknowledgement of a published message.
+type KafkaDelivery struct {
+	Partition int32
+	Offset    int64
+}
+
+// Producer publishes the messages to kafka, Produce blocks until the message
+// is acknowledged by the brokers or ctx is done.
+type Producer interface {
+	Produce(ctx context.Context, msg *KafkaMessage) (KafkaDelivery, error)
+}
+
+var (
+	producerMu sync.RWMutex
+	producer   Producer
+)
+
+// SetProducer sets the producer used by KAFKA executor, e.g. a client of the
+// brokers in production or an in-memory one in tests.
+func SetProducer(p Producer) {
+	producerMu.Lock()
+	defer producerMu.Unlock()
+	producer = p
+}
+
+// HasProducer returns true if the producer of KAFKA executor has been set.
+func HasProducer() bool {
+	return getProducer() != nil
+}
+
+func getProducer() Producer {
+	producerMu.RLock()
+	defer producerMu.RUnlock()
+	return producer
+}
+
+// KafkaContent describes TaskConfig#Content of KAFKA executor, the message
+// headers come from TaskConfig#Headers.
+type KafkaContent struct {
+	Topic string `json:"topic"`
+	Key   string `json:"key,omitempty"`
+	// Value will be sent as it is if it's a JSON string, otherwise be sent as JSON.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// executeKafka publishes the message described by conf, the task is finished
+// once the message is acknowledged.
+func executeKafka(ctx context.Context, conf *taskconfig.TaskConfig) Result {
+	p := getProducer()
+	if p == nil {
+		return Failed(0, "kafka producer is not configured")
+	}
+	msg, err := buildMessage(ctx, conf)
+	if err != nil {
+		return Failed(0, err.Error())
+	}
+
+	d, err := p.Produce(ctx, msg)
+	if err != nil {
+		return Failed(0, fmt.Sprintf("deliver to %s failed: %v", msg.Topic, err))
+	}
+	return Finished(0, fmt.Sprintf("delivered to %s[%d]@%d", msg.Topic, d.Partition, d.Offset))
+}
+
+// buildMessage returns the message from TaskConfig.
+func buildMessage(ctx context.Context, conf *taskconfig.TaskConfig) (*KafkaMessage, error) {
+	if conf == nil {
+		return nil, fmt.Errorf("task config not found")
+	}
+	var content KafkaContent
+	if err := json.Unmarshal(conf.Content, &content); err != nil {
+		return nil, fmt.Errorf("invalid kafka content: %v", err)
+	}
+	if content.Topic == "" {
+		return nil, fmt.Errorf("topic of kafka content is required")
+	}
+
+	msg := &KafkaMessage{
+		Topic:   content.Topic,
+		Key:     content.Key,
+		Headers: make(map[string]string),
+	}
+	if len(content.Value) > 0 && string(content.Value) != "null" {
+		var raw string
+		if err := json.Unmarshal(content.Value, &raw); err == nil {
+			msg.Value = []byte(raw)
+		} else {
+			msg.Value = content.Value
+		}
+	}
+	if len(conf.Headers) > 0 {
+		var headers map[string]interface{}
+		if err := json.Unmarshal(conf.Headers, &headers); err != nil {
+			return nil, fmt.Errorf("invalid kafka headers: %v", err)
+		}
+		for k, v := range headers {
+			msg.Headers[k] = fmt.Sprint(v)
+		}
+	}
+	if shard, ok := ShardFrom(ctx); ok {
+		for k, v := range shard.headers() {
+			msg.Headers[k] = v
+		}
+	}
+	return msg, nil
+}
@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+// memoryProducer keeps the messages in memory, fails while err is set.
+type memoryProducer struct {
+	mu       sync.Mutex
+	messages []*KafkaMessage
+	err      error
+}
+
+func (p *memoryProducer) Produce(ctx context.Context, msg *KafkaMessage) (KafkaDelivery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return KafkaDelivery{}, p.err
+	}
+	if err := ctx.Err(); err != nil {
+		return KafkaDelivery{}, err
+	}
+	p.messages = append(p.messages, msg)
+	return KafkaDelivery{Partition: 0, Offset: int64(len(p.messages) - 1)}, nil
+}
+
+func TestExecuteKafka(t *testing.T) {
+	p := &memoryProducer{}
+	SetProducer(p)
+	defer SetProducer(nil)
+
+	conf := &taskconfig.TaskConfig{
+		Headers: datatypes.JSON(`{"X-Source":"galaxy"}`),
+		Content: datatypes.JSON(`{"topic":"orders","key":"42","value":{"id":42}}`),
+	}
+	ctx := WithShard(context.Background(), Shard{Index: 0, Total: 2})
+	res := Execute(ctx, &task.Task{Executor: task.KAFKA, Timeout: 3}, conf)
+	assert.EqualValues(t, schedulingrecord.FINISHED, res.Status)
+	assert.Equal(t, "delivered to orders[0]@0", res.Message)
+
+	assert.Equal(t, 1, len(p.messages))
+	msg := p.messages[0]
+	assert.Equal(t, "orders", msg.Topic)
+	assert.Equal(t, "42", msg.Key)
+	assert.JSONEq(t, `{"id":42}`, string(msg.Value))
+	assert.Equal(t, "galaxy", msg.Headers["X-Source"])
+	assert.Equal(t, "2", msg.Headers[ShardTotalHeader])
+
+	conf.Content = datatypes.JSON(`{"topic":"orders","value":"raw"}`)
+	res = Execute(context.Background(), &task.Task{Executor: task.KAFKA}, conf)
+	assert.EqualValues(t, schedulingrecord.FINISHED, res.Status)
+	assert.Equal(t, "raw", string(p.messages[1].Value))
+}
+
+func TestExecuteKafkaFailed(t *testing.T) {
+	conf := &taskconfig.TaskConfig{Content: datatypes.JSON(`{"topic":"orders"}`)}
+	res := Execute(context.Background(), &task.Task{Executor: task.KAFKA}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, "kafka producer is not configured", res.Message)
+
+	SetProducer(&memoryProducer{err: errors.New("leader not available")})
+	defer SetProducer(nil)
+
+	res = Execute(context.Background(), &task.Task{Executor: task.KAFKA}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, 0, res.Code)
+	assert.Equal(t, "deliver to orders failed: leader not available", res.Message)
+
+	conf.Content = datatypes.JSON(`{"key":"42"}`)
+	res = Execute(context.Background(), &task.Task{Executor: task.KAFKA}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, "topic of kafka content is required", res.Message)
+}
+
+func TestKafkaProducerUnreachable(t *testing.T) {
+	// the port is closed once the listener is.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	assert.False(t, HasProducer())
+	SetProducer(NewKafkaProducer([]string{addr}, time.Second))
+	defer SetProducer(nil)
+	assert.True(t, HasProducer())
+
+	conf := &taskconfig.TaskConfig{Content: datatypes.JSON(`{"topic":"orders","value":"raw"}`)}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	res := Execute(ctx, &task.Task{Executor: task.KAFKA}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Contains(t, res.Message, "deliver to orders failed")
+}
@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaPartitionsTTL how long the partitions of a topic are cached.
+const kafkaPartitionsTTL = time.Minute
+
+// kafkaProducer publishes every message by a produce request to the leader of
+// its partition, which is acknowledged by all in-sync replicas. The messages
+// with the same key go to the same partition.
+type kafkaProducer struct {
+	client   *kafka.Client
+	balancer kafka.Balancer
+
+	mu sync.Mutex
+	// topics topic -> its partitions.
+	topics map[string]*kafkaPartitions
+}
+
+// kafkaPartitions the cached partitions of a topic.
+type kafkaPartitions struct {
+	ids       []int
+	expiredAt time.Time
+}
+
+// NewKafkaProducer returns the producer of the brokers, e.g. localhost:9092,
+// the requests to brokers are limited by timeout.
+func NewKafkaProducer(brokers []string, timeout time.Duration) Producer {
+	return &kafkaProducer{
+		client:   &kafka.Client{Addr: kafka.TCP(brokers...), Timeout: timeout},
+		balancer: &kafka.Hash{},
+		topics:   make(map[string]*kafkaPartitions),
+	}
+}
+
+// Produce implements Producer.
+func (p *kafkaProducer) Produce(ctx context.Context, msg *KafkaMessage) (KafkaDelivery, error) {
+	partitions, err := p.partitions(ctx, msg.Topic)
+	if err != nil {
+		return KafkaDelivery{}, err
+	}
+	var key []byte
+	if msg.Key != "" {
+		key = []byte(msg.Key)
+	}
+	record := kafka.Record{Key: kafka.NewBytes(key), Value: kafka.NewBytes(msg.Value)}
+	for k, v := range msg.Headers {
+		record.Headers = append(record.Headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	partition := p.balancer.Balance(kafka.Message{Key: key}, partitions...)
+
+	res, err := p.client.Produce(ctx, &kafka.ProduceRequest{
+		Topic:        msg.Topic,
+		Partition:    partition,
+		RequiredAcks: kafka.RequireAll,
+		Records:      kafka.NewRecordReader(record),
+	})
+	if err != nil {
+		return KafkaDelivery{}, err
+	}
+	if res.Error != nil {
+		// e.g. the leader has been moved, reloads the partitions next time.
+		p.forget(msg.Topic)
+		return KafkaDelivery{}, res.Error
+	}
+	return KafkaDelivery{Partition: int32(partition), Offset: res.BaseOffset}, nil
+}
+
+// partitions returns the partitions of topic, they are loaded from the
+// brokers once expired.
+func (p *kafkaProducer) partitions(ctx context.Context, topic string) ([]int, error) {
+	p.mu.Lock()
+	cached, ok := p.topics[topic]
+	p.mu.Unlock()
+	if ok && time.Now().Before(cached.expiredAt) {
+		return cached.ids, nil
+	}
+
+	res, err := p.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Topics) == 0 {
+		return nil, fmt.Errorf("topic %s not found", topic)
+	}
+	if err := res.Topics[0].Error; err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(res.Topics[0].Partitions))
+	for _, partition := range res.Topics[0].Partitions {
+		ids = append(ids, partition.ID)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("topic %s has no partitions", topic)
+	}
+
+	p.mu.Lock()
+	p.topics[topic] = &kafkaPartitions{ids: ids, expiredAt: time.Now().Add(kafkaPartitionsTTL)}
+	p.mu.Unlock()
+	return ids, nil
+}
+
+// forget drops the cached partitions of topic.
+func (p *kafkaProducer) forget(topic string) {
+	p.mu.Lock()
+	delete(p.topics, topic)
+	p.mu.Unlock()
+}
@@ -0,0 +1,13 @@
+package executor
+
+import "os/exec"
+
+// setProcessGroup is not supported on windows.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process of the started command only.
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,81 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/galaxy-center/galaxy/models/task"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+)
+
+// Executor performs a task with its config, the implementations should
+// return once ctx is done, which is cancelled after Task#Timeout.
+type Executor interface {
+	Execute(ctx context.Context, t *task.Task, conf *taskconfig.TaskConfig) Result
+}
+
+// ExecutorFunc adapts an ordinary function to Executor.
+type ExecutorFunc func(ctx context.Context, t *task.Task, conf *taskconfig.TaskConfig) Result
+
+// Execute calls f(ctx, t, conf).
+func (f ExecutorFunc) Execute(ctx context.Context, t *task.Task, conf *taskconfig.TaskConfig) Result {
+	return f(ctx, t, conf)
+}
+
+var (
+	executorsMu sync.RWMutex
+	executors   = make(map[task.Executor]Executor)
+)
+
+func init() {
+	Register(task.HTTP, ExecutorFunc(func(ctx context.Context, _ *task.Task, conf *taskconfig.TaskConfig) Result {
+		return executeHTTP(ctx, conf)
+	}))
+	Register(task.KAFKA, ExecutorFunc(func(ctx context.Context, _ *task.Task, conf *taskconfig.TaskConfig) Result {
+		return executeKafka(ctx, conf)
+	}))
+	Register(task.RPC, ExecutorFunc(func(ctx context.Context, _ *task.Task, conf *taskconfig.TaskConfig) Result {
+		return executeRPC(ctx, conf)
+	}))
+	Register(task.SCRIPT, ExecutorFunc(executeScript))
+	Register(task.COMMAND, ExecutorFunc(func(ctx context.Context, _ *task.Task, conf *taskconfig.TaskConfig) Result {
+		return executeCommand(ctx, conf)
+	}))
+}
+
+// Register makes an executor available by name, the tasks whose Task#Executor
+// is name are performed by it. It panics if name is empty or registered twice,
+// so it's supposed to be called in init.
+func Register(name task.Executor, e Executor) {
+	executorsMu.Lock()
+	defer executorsMu.Unlock()
+	if name == "" || e == nil {
+		panic("executor: Register executor is empty")
+	}
+	if _, dup := executors[name]; dup {
+		panic(fmt.Sprintf("executor: Register called twice for executor %s", name))
+	}
+	executors[name] = e
+}
+
+// Lookup returns the executor registered by name.
+func Lookup(name task.Executor) (Executor, bool) {
+	executorsMu.RLock()
+	defer executorsMu.RUnlock()
+	e, ok := executors[name]
+	return e, ok
+}
+
+// Names returns the sorted names of registered executors.
+func Names() []string {
+	executorsMu.RLock()
+	defer executorsMu.RUnlock()
+	names := make([]string, 0, len(executors))
+	for name := range executors {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,30 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	const custom task.Executor = "CUSTOM"
+	Register(custom, ExecutorFunc(func(ctx context.Context, t *task.Task, conf *taskconfig.TaskConfig) Result {
+		return Finished(0, "custom executed "+t.Name)
+	}))
+	assert.Equal(t, []string{"COMMAND", "CUSTOM", "HTTP", "KAFKA", "RPC", "SCRIPT"}, Names())
+
+	res := Execute(context.Background(), &task.Task{Name: "job", Executor: custom}, nil)
+	assert.EqualValues(t, schedulingrecord.FINISHED, res.Status)
+	assert.Equal(t, "custom executed job", res.Message)
+
+	assert.Panics(t, func() { Register(custom, ExecutorFunc(nil)) }, "duplicate executor should panic")
+	assert.Panics(t, func() { Register("", ExecutorFunc(nil)) }, "empty name should panic")
+
+	res = Execute(context.Background(), &task.Task{Executor: "UNKNOWN"}, nil)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, "executor UNKNOWN is not registered", res.Message)
+}
@@ -0,0 +1,307 @@
+package executor
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var (
+	descriptorsMu sync.RWMutex
+	// descriptors the registered descriptors, the methods which are not found
+	// here are resolved by server reflection.
+	descriptors = new(protoregistry.Files)
+)
+
+// RPCContent describes TaskConfig#Content of RPC executor, the request
+// metadata come from TaskConfig#Headers. Result#Code is the gRPC status code.
+type RPCContent struct {
+	// Target the address of server, e.g. localhost:50051.
+	Target string `json:"target"`
+	// Method the full method name, e.g. grpc.health.v1.Health/Check.
+	Method string `json:"method"`
+	// Request the request message in JSON.
+	Request json.RawMessage `json:"request,omitempty"`
+	// TLS connects to the server with TLS, default is plaintext.
+	TLS bool `json:"tls,omitempty"`
+}
+
+// RegisterDescriptorSet registers the descriptors of the servers which don't
+// support reflection, the set should include all the imports, e.g. generated
+// by `protoc --include_imports --descriptor_set_out`.
+func RegisterDescriptorSet(set *descriptorpb.FileDescriptorSet) error {
+	protos := make(map[string]*descriptorpb.FileDescriptorProto, len(set.GetFile()))
+	for _, fd := range set.GetFile() {
+		protos[fd.GetName()] = fd
+	}
+	files, err := newFiles(protos)
+	if err != nil {
+		return err
+	}
+
+	descriptorsMu.Lock()
+	defer descriptorsMu.Unlock()
+	var rangeErr error
+	files.RangeFiles(func(f protoreflect.FileDescriptor) bool {
+		if _, err := descriptors.FindFileByPath(f.Path()); err == nil {
+			return true
+		}
+		rangeErr = descriptors.RegisterFile(f)
+		return rangeErr == nil
+	})
+	return rangeErr
+}
+
+// executeRPC invokes the unary method described by conf.
+func executeRPC(ctx context.Context, conf *taskconfig.TaskConfig) Result {
+	content, err := parseRPCContent(conf)
+	if err != nil {
+		return Failed(0, err.Error())
+	}
+	service, method := splitMethod(content.Method)
+	if service == "" || method == "" {
+		return Failed(0, fmt.Sprintf("invalid rpc method %q", content.Method))
+	}
+
+	creds := grpc.WithInsecure()
+	if content.TLS {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+	}
+	conn, err := grpc.DialContext(ctx, content.Target, creds)
+	if err != nil {
+		return Failed(0, fmt.Sprintf("dial %s failed: %v", content.Target, err))
+	}
+	defer conn.Close()
+
+	md, err := findMethod(ctx, conn, service, method)
+	if err != nil {
+		return Failed(0, err.Error())
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return Failed(0, fmt.Sprintf("rpc method %s is not unary", content.Method))
+	}
+	req := dynamicpb.NewMessage(md.Input())
+	if len(content.Request) > 0 {
+		if err := protojson.Unmarshal(content.Request, req); err != nil {
+			return Failed(0, fmt.Sprintf("invalid rpc request: %v", err))
+		}
+	}
+	header, err := rpcMetadata(ctx, conf)
+	if err != nil {
+		return Failed(0, err.Error())
+	}
+
+	resp := dynamicpb.NewMessage(md.Output())
+	if err := conn.Invoke(metadata.NewOutgoingContext(ctx, header), "/"+service+"/"+method, req, resp); err != nil {
+		st := status.Convert(err)
+		return Failed(int(st.Code()), fmt.Sprintf("code %s: %s", st.Code(), st.Message()))
+	}
+	body, err := protojson.Marshal(resp)
+	if err != nil {
+		return Finished(0, fmt.Sprintf("code OK: marshal response failed: %v", err))
+	}
+	return Finished(0, fmt.Sprintf("code OK: %s", truncate(string(body), maxResponseSize)))
+}
+
+// parseRPCContent returns the content of TaskConfig.
+func parseRPCContent(conf *taskconfig.TaskConfig) (*RPCContent, error) {
+	if conf == nil {
+		return nil, fmt.Errorf("task config not found")
+	}
+	var content RPCContent
+	if err := json.Unmarshal(conf.Content, &content); err != nil {
+		return nil, fmt.Errorf("invalid rpc content: %v", err)
+	}
+	if content.Target == "" || content.Method == "" {
+		return nil, fmt.Errorf("target and method of rpc content are required")
+	}
+	return &content, nil
+}
+
+// splitMethod splits the full method name into service and method.
+func splitMethod(fullMethod string) (service, method string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	i := strings.LastIndex(fullMethod, "/")
+	if i < 0 {
+		return "", ""
+	}
+	return fullMethod[:i], fullMethod[i+1:]
+}
+
+// rpcMetadata returns the request metadata from TaskConfig#Headers and shard.
+func rpcMetadata(ctx context.Context, conf *taskconfig.TaskConfig) (metadata.MD, error) {
+	header := make(map[string]string)
+	if len(conf.Headers) > 0 {
+		var headers map[string]interface{}
+		if err := json.Unmarshal(conf.Headers, &headers); err != nil {
+			return nil, fmt.Errorf("invalid rpc headers: %v", err)
+		}
+		for k, v := range headers {
+			header[k] = fmt.Sprint(v)
+		}
+	}
+	if shard, ok := ShardFrom(ctx); ok {
+		for k, v := range shard.headers() {
+			header[k] = v
+		}
+	}
+	return metadata.New(header), nil
+}
+
+// findMethod resolves the method from the registered descriptors, otherwise
+// by server reflection.
+func findMethod(ctx context.Context, conn *grpc.ClientConn, service, method string) (protoreflect.MethodDescriptor, error) {
+	descriptorsMu.RLock()
+	md, err := lookupMethod(descriptors, service, method)
+	descriptorsMu.RUnlock()
+	if err == nil {
+		return md, nil
+	}
+
+	files, err := reflectFiles(ctx, conn, service)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s by reflection failed: %v", service, err)
+	}
+	return lookupMethod(files, service, method)
+}
+
+// lookupMethod returns the method of service from files.
+func lookupMethod(files *protoregistry.Files, service, method string) (protoreflect.MethodDescriptor, error) {
+	d, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("rpc service %s not found", service)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a rpc service", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, fmt.Errorf("rpc method %s/%s not found", service, method)
+	}
+	return md, nil
+}
+
+// reflectFiles loads the file which contains service and its imports from
+// the server reflection.
+func reflectFiles(ctx context.Context, conn *grpc.ClientConn, service string) (*protoregistry.Files, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+
+	protos := make(map[string]*descriptorpb.FileDescriptorProto)
+	request := func(req *rpb.ServerReflectionRequest) error {
+		if err := stream.Send(req); err != nil {
+			return err
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return fmt.Errorf("code %d: %s", e.GetErrorCode(), e.GetErrorMessage())
+		}
+		for _, b := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := new(descriptorpb.FileDescriptorProto)
+			if err := proto.Unmarshal(b, fd); err != nil {
+				return err
+			}
+			protos[fd.GetName()] = fd
+		}
+		return nil
+	}
+
+	if err := request(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	}); err != nil {
+		return nil, err
+	}
+	// the server may omit the imports which have been sent, loads them by name.
+	for missing := missingImports(protos); len(missing) > 0; missing = missingImports(protos) {
+		for _, name := range missing {
+			if err := request(&rpb.ServerReflectionRequest{
+				MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: name},
+			}); err != nil {
+				return nil, err
+			}
+			if _, ok := protos[name]; !ok {
+				return nil, fmt.Errorf("descriptor of %s not found", name)
+			}
+		}
+	}
+	return newFiles(protos)
+}
+
+// missingImports returns the imports which are neither loaded nor linked in.
+func missingImports(protos map[string]*descriptorpb.FileDescriptorProto) []string {
+	var missing []string
+	for _, fd := range protos {
+		for _, dep := range fd.GetDependency() {
+			if _, ok := protos[dep]; ok {
+				continue
+			}
+			if _, err := protoregistry.GlobalFiles.FindFileByPath(dep); err == nil {
+				continue
+			}
+			missing = append(missing, dep)
+		}
+	}
+	return missing
+}
+
+// newFiles builds the descriptors in order of imports, the imports which are
+// absent are taken from the ones linked in, e.g. google/protobuf/*.proto.
+func newFiles(protos map[string]*descriptorpb.FileDescriptorProto) (*protoregistry.Files, error) {
+	files := new(protoregistry.Files)
+	var add func(name string) error
+	add = func(name string) error {
+		if _, err := files.FindFileByPath(name); err == nil {
+			return nil
+		}
+		fd, ok := protos[name]
+		if !ok {
+			f, err := protoregistry.GlobalFiles.FindFileByPath(name)
+			if err != nil {
+				return fmt.Errorf("descriptor of %s not found", name)
+			}
+			return files.RegisterFile(f)
+		}
+		for _, dep := range fd.GetDependency() {
+			if err := add(dep); err != nil {
+				return err
+			}
+		}
+		f, err := protodesc.NewFile(fd, files)
+		if err != nil {
+			return err
+		}
+		return files.RegisterFile(f)
+	}
+	for name := range protos {
+		if err := add(name); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
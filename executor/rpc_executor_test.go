@@ -0,0 +1,112 @@
+package executor
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"gorm.io/datatypes"
+)
+
+// healthServer records the metadata of the last request.
+type healthServer struct {
+	*health.Server
+	md metadata.MD
+}
+
+func (s *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s.md, _ = metadata.FromIncomingContext(ctx)
+	return s.Server.Check(ctx, req)
+}
+
+// startRPCServer starts a health server, returns its address and stop func.
+func startRPCServer(t *testing.T, withReflection bool) (*healthServer, string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	s := grpc.NewServer()
+	hs := &healthServer{Server: health.NewServer()}
+	hs.SetServingStatus("galaxy", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+	if withReflection {
+		reflection.Register(s)
+	}
+	go s.Serve(lis)
+	return hs, lis.Addr().String(), s.Stop
+}
+
+func TestExecuteRPC(t *testing.T) {
+	hs, addr, stop := startRPCServer(t, true)
+	defer stop()
+
+	conf := &taskconfig.TaskConfig{
+		Headers: datatypes.JSON(`{"X-Auth":"secret"}`),
+		Content: datatypes.JSON(`{"target":"` + addr + `","method":"grpc.health.v1.Health/Check","request":{"service":"galaxy"}}`),
+	}
+	ctx := WithShard(context.Background(), Shard{Index: 1, Total: 2})
+	res := Execute(ctx, &task.Task{Executor: task.RPC, Timeout: 3}, conf)
+	assert.EqualValues(t, schedulingrecord.FINISHED, res.Status, res.Message)
+	assert.JSONEq(t, `{"status":"SERVING"}`, res.Message[len("code OK: "):])
+	assert.Equal(t, []string{"secret"}, hs.md.Get("x-auth"))
+	assert.Equal(t, []string{"1"}, hs.md.Get(ShardIndexHeader))
+
+	conf.Content = datatypes.JSON(`{"target":"` + addr + `","method":"/grpc.health.v1.Health/Check","request":{"service":"unknown"}}`)
+	res = Execute(context.Background(), &task.Task{Executor: task.RPC, Timeout: 3}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, int(codes.NotFound), res.Code)
+	assert.Equal(t, "code NotFound: unknown service", res.Message)
+
+	conf.Content = datatypes.JSON(`{"target":"` + addr + `","method":"grpc.health.v1.Health/Watch"}`)
+	res = Execute(context.Background(), &task.Task{Executor: task.RPC, Timeout: 3}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, "rpc method grpc.health.v1.Health/Watch is not unary", res.Message)
+
+	conf.Content = datatypes.JSON(`{"target":"` + addr + `","method":"grpc.health.v1.Health/Check","request":{"unknown":1}}`)
+	res = Execute(context.Background(), &task.Task{Executor: task.RPC, Timeout: 3}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Contains(t, res.Message, "invalid rpc request")
+}
+
+func TestExecuteRPCWithDescriptorSet(t *testing.T) {
+	_, addr, stop := startRPCServer(t, false)
+	defer stop()
+
+	conf := &taskconfig.TaskConfig{
+		Content: datatypes.JSON(`{"target":"` + addr + `","method":"grpc.health.v1.Health/Check","request":{"service":"galaxy"}}`),
+	}
+	res := Execute(context.Background(), &task.Task{Executor: task.RPC, Timeout: 3}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Contains(t, res.Message, "resolve grpc.health.v1.Health by reflection failed")
+
+	err := RegisterDescriptorSet(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto)},
+	})
+	assert.Nil(t, err)
+
+	res = Execute(context.Background(), &task.Task{Executor: task.RPC, Timeout: 3}, conf)
+	assert.EqualValues(t, schedulingrecord.FINISHED, res.Status, res.Message)
+	assert.JSONEq(t, `{"status":"SERVING"}`, res.Message[len("code OK: "):])
+}
+
+func TestExecuteRPCInvalidConfig(t *testing.T) {
+	conf := &taskconfig.TaskConfig{Content: datatypes.JSON(`{"target":"localhost:0"}`)}
+	res := Execute(context.Background(), &task.Task{Executor: task.RPC}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, "target and method of rpc content are required", res.Message)
+
+	conf = &taskconfig.TaskConfig{Content: datatypes.JSON(`{"target":"localhost:0","method":"Check"}`)}
+	res = Execute(context.Background(), &task.Task{Executor: task.RPC}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, `invalid rpc method "Check"`, res.Message)
+}
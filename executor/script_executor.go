@@ -0,0 +1,203 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/galaxy-center/galaxy/models/task"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkjson"
+	"go.starlark.net/starlarkstruct"
+)
+
+const (
+	// defaultScriptMaxSteps the max computation steps of a script while
+	// ScriptContent#MaxSteps is absent.
+	defaultScriptMaxSteps = 10000000
+	// maxScriptHTTPBodySize the max size of response body read by http_get.
+	maxScriptHTTPBodySize = 1 << 20
+	// scriptEntry the function called after the script is loaded if defined,
+	// its return value is kept in the record message.
+	scriptEntry = "main"
+)
+
+// ScriptContent describes TaskConfig#Content of SCRIPT executor, the content
+// can also be the script itself as a JSON string.
+//
+// The script is Starlark, it can use the builtins:
+//
+//	http_get(url, headers={}) returns struct(status, body)
+//	json.encode(x), json.decode(s)
+//	log(*args) writes to the log of Galaxy
+//	now() returns the unix time in seconds
+//
+// The prints and the return value of main() are kept in the record message.
+type ScriptContent struct {
+	Script string `json:"script"`
+	// MaxSteps limits the computation steps of script, the script fails once
+	// it's exceeded.
+	MaxSteps uint64 `json:"max_steps,omitempty"`
+}
+
+// executeScript runs the script described by conf until it's finished or ctx
+// is done.
+func executeScript(ctx context.Context, t *task.Task, conf *taskconfig.TaskConfig) Result {
+	content, err := parseScriptContent(conf)
+	if err != nil {
+		return Failed(0, err.Error())
+	}
+
+	// the prints are captured up to twice of maxResponseSize, the rest are
+	// discarded, since the message is truncated to maxResponseSize anyway.
+	output := &cappedBuffer{max: 2 * maxResponseSize}
+	thread := &starlark.Thread{
+		Name: fmt.Sprintf("task-%d", t.ID),
+		Print: func(_ *starlark.Thread, msg string) {
+			fmt.Fprintln(output, msg)
+		},
+	}
+	thread.SetLocal("context", ctx)
+	if content.MaxSteps > 0 {
+		thread.SetMaxExecutionSteps(content.MaxSteps)
+	} else {
+		thread.SetMaxExecutionSteps(defaultScriptMaxSteps)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-done:
+		}
+	}()
+
+	res, err := runScript(thread, t, content.Script)
+	if err == nil && res != starlark.None {
+		fmt.Fprintf(output, "return: %s", res)
+	}
+	message := strings.TrimSuffix(output.String(), "\n")
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			err = fmt.Errorf("%s", evalErr.Backtrace())
+		}
+		message = strings.TrimPrefix(message+"\n"+err.Error(), "\n")
+	}
+	message = truncate(message, maxResponseSize)
+	if err != nil {
+		return Failed(0, message)
+	}
+	return Finished(0, message)
+}
+
+// parseScriptContent returns the content of TaskConfig.
+func parseScriptContent(conf *taskconfig.TaskConfig) (*ScriptContent, error) {
+	if conf == nil {
+		return nil, fmt.Errorf("task config not found")
+	}
+	var content ScriptContent
+	if err := json.Unmarshal(conf.Content, &content.Script); err != nil {
+		if err := json.Unmarshal(conf.Content, &content); err != nil {
+			return nil, fmt.Errorf("invalid script content: %v", err)
+		}
+	}
+	if strings.TrimSpace(content.Script) == "" {
+		return nil, fmt.Errorf("script of script content is required")
+	}
+	return &content, nil
+}
+
+// runScript loads the script and calls its main() if defined.
+func runScript(thread *starlark.Thread, t *task.Task, script string) (starlark.Value, error) {
+	globals, err := starlark.ExecFile(thread, fmt.Sprintf("task-%d.star", t.ID), script, scriptBuiltins(t))
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := globals[scriptEntry]
+	if !ok {
+		return starlark.None, nil
+	}
+	return starlark.Call(thread, entry, nil, nil)
+}
+
+// scriptBuiltins returns the builtins can be used by the script of task.
+func scriptBuiltins(t *task.Task) starlark.StringDict {
+	return starlark.StringDict{
+		"http_get": starlark.NewBuiltin("http_get", scriptHTTPGet),
+		"json":     starlarkjson.Module,
+		"log": starlark.NewBuiltin("log", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if len(kwargs) > 0 {
+				return nil, fmt.Errorf("%s: unexpected keyword arguments", b.Name())
+			}
+			msg := make([]string, 0, len(args))
+			for _, arg := range args {
+				if s, ok := starlark.AsString(arg); ok {
+					msg = append(msg, s)
+				} else {
+					msg = append(msg, arg.String())
+				}
+			}
+			executorLog.WithField("task", t.ID).Info(strings.Join(msg, " "))
+			return starlark.None, nil
+		}),
+		"now": starlark.NewBuiltin("now", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+				return nil, err
+			}
+			return starlark.Float(float64(time.Now().UnixNano()) / float64(time.Second)), nil
+		}),
+	}
+}
+
+// scriptHTTPGet implements http_get(url, headers={}).
+func scriptHTTPGet(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var (
+		url     string
+		headers *starlark.Dict
+	)
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "url", &url, "headers?", &headers); err != nil {
+		return nil, err
+	}
+	ctx, _ := thread.Local("context").(context.Context)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	if headers != nil {
+		for _, item := range headers.Items() {
+			k, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, fmt.Errorf("%s: header name should be string, got %s", b.Name(), item[0].Type())
+			}
+			v, ok := starlark.AsString(item[1])
+			if !ok {
+				v = item[1].String()
+			}
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxScriptHTTPBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("%s: read body failed: %v", b.Name(), err)
+	}
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"status": starlark.MakeInt(resp.StatusCode),
+		"body":   starlark.String(body),
+	}), nil
+}
@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+// scriptConfig returns the config of script as a JSON string.
+func scriptConfig(script string) *taskconfig.TaskConfig {
+	b, _ := json.Marshal(script)
+	return &taskconfig.TaskConfig{Content: datatypes.JSON(b)}
+}
+
+func TestExecuteScript(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-Auth"))
+		w.Write([]byte(`{"count":2}`))
+	}))
+	defer server.Close()
+
+	script := `
+def main():
+    resp = http_get("` + server.URL + `", headers={"X-Auth": "secret"})
+    data = json.decode(resp.body)
+    print("status", resp.status)
+    log("fetched", data["count"])
+    return {"count": data["count"] * 2, "fresh": now() > 0}
+`
+	res := Execute(context.Background(), &task.Task{Executor: task.SCRIPT, Timeout: 3}, scriptConfig(script))
+	assert.EqualValues(t, schedulingrecord.FINISHED, res.Status, res.Message)
+	assert.Equal(t, "status 200\nreturn: {\"count\": 4, \"fresh\": True}", res.Message)
+
+	conf := &taskconfig.TaskConfig{Content: datatypes.JSON(`{"script":"print(json.encode([1, 2]))"}`)}
+	res = Execute(context.Background(), &task.Task{Executor: task.SCRIPT}, conf)
+	assert.EqualValues(t, schedulingrecord.FINISHED, res.Status, res.Message)
+	assert.Equal(t, "[1,2]", res.Message)
+}
+
+func TestExecuteScriptFailed(t *testing.T) {
+	res := Execute(context.Background(), &task.Task{Executor: task.SCRIPT}, scriptConfig(`
+print("before")
+fail("boom")
+`))
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.True(t, strings.HasPrefix(res.Message, "before\n"), res.Message)
+	assert.Contains(t, res.Message, "fail: boom")
+
+	res = Execute(context.Background(), &task.Task{Executor: task.SCRIPT}, scriptConfig(`x = `))
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+
+	res = Execute(context.Background(), &task.Task{Executor: task.SCRIPT}, scriptConfig(`load("os.star", "system")`))
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status, "load is not allowed")
+
+	res = Execute(context.Background(), &task.Task{Executor: task.SCRIPT}, &taskconfig.TaskConfig{Content: datatypes.JSON(`{}`)})
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Equal(t, "script of script content is required", res.Message)
+}
+
+func TestExecuteScriptLimits(t *testing.T) {
+	loop := `
+def main():
+    n = 0
+    for i in range(100000000):
+        n += i
+    return n
+`
+	conf := &taskconfig.TaskConfig{Content: datatypes.JSON(`{"script":` + quote(loop) + `,"max_steps":1000}`)}
+	res := Execute(context.Background(), &task.Task{Executor: task.SCRIPT}, conf)
+	assert.EqualValues(t, schedulingrecord.FAILED, res.Status)
+	assert.Contains(t, res.Message, "too many steps")
+
+	start := time.Now()
+	conf = &taskconfig.TaskConfig{Content: datatypes.JSON(`{"script":` + quote(loop) + `,"max_steps":100000000000}`)}
+	res = Execute(context.Background(), &task.Task{Executor: task.SCRIPT, Timeout: 1}, conf)
+	assert.EqualValues(t, schedulingrecord.TIMEOUT, res.Status)
+	assert.True(t, time.Since(start) < 2*time.Second, "timeout should be honored")
+}
+
+func TestExecuteScriptOutputCapped(t *testing.T) {
+	script := `
+def main():
+    for i in range(100000):
+        print("中文输出")
+    return "done"
+`
+	res := Execute(context.Background(), &task.Task{Executor: task.SCRIPT}, scriptConfig(script))
+	assert.EqualValues(t, schedulingrecord.FINISHED, res.Status)
+	assert.True(t, utf8.ValidString(res.Message), "message should be valid UTF-8")
+	assert.True(t, len(res.Message) <= maxResponseSize+len("..."), "message should be truncated")
+	assert.True(t, strings.HasSuffix(res.Message, "..."))
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
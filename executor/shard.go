@@ -0,0 +1,39 @@
+package executor
+
+import (
+	"context"
+	"strconv"
+)
+
+// Shard headers of HTTP executor.
+const (
+	ShardIndexHeader = "X-Galaxy-Shard-Index"
+	ShardTotalHeader = "X-Galaxy-Shard-Total"
+)
+
+// Shard describes which part of a MULTIPLE task current node works on.
+type Shard struct {
+	Index int `json:"index"`
+	Total int `json:"total"`
+}
+
+type shardKey struct{}
+
+// WithShard returns a copy of ctx which carries the shard.
+func WithShard(ctx context.Context, s Shard) context.Context {
+	return context.WithValue(ctx, shardKey{}, s)
+}
+
+// ShardFrom returns the shard carried by ctx.
+func ShardFrom(ctx context.Context) (Shard, bool) {
+	s, ok := ctx.Value(shardKey{}).(Shard)
+	return s, ok
+}
+
+// headers returns the shard as http headers.
+func (s Shard) headers() map[string]string {
+	return map[string]string{
+		ShardIndexHeader: strconv.Itoa(s.Index),
+		ShardTotalHeader: strconv.Itoa(s.Total),
+	}
+}
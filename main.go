@@ -4,17 +4,26 @@ package main
 import (
 	"context"
 	"net/http"
+	"time"
+	// embeds the IANA timezones of tasks, in case the host has none.
+	_ "time/tzdata"
 
 	"github.com/galaxy-center/galaxy/config"
+	"github.com/galaxy-center/galaxy/executor"
 	dbProvider "github.com/galaxy-center/galaxy/lifecycle"
 	logger "github.com/galaxy-center/galaxy/log"
+	"github.com/galaxy-center/galaxy/middleware"
 	"github.com/galaxy-center/galaxy/migrate"
 	"github.com/galaxy-center/galaxy/resources"
+	"github.com/galaxy-center/galaxy/services"
 	"github.com/gin-gonic/gin"
 	_ "github.com/go-sql-driver/mysql"
 )
 
-const ()
+const (
+	// defaultKafkaTimeout is used while config.KafkaConfig#Timeout is absent.
+	defaultKafkaTimeout = 10 * time.Second
+)
 
 var (
 	log     = logger.Get()
@@ -24,12 +33,33 @@ var (
 
 func main() {
 	mainLog.Info("Galaxy Application starting.")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	setupKafka()
+	middleware.Start(ctx)
+	services.StartDelayBatcher(ctx)
+
 	router := gin.Default()
 	registers(router)
 	router.Run(":8080")
 
 }
 
+// setupKafka sets the producer of KAFKA executor if the brokers are configured.
+func setupKafka() {
+	conf := config.Global().Kafka
+	if len(conf.Brokers) == 0 {
+		mainLog.Warn("Kafka brokers are not configured, the KAFKA executor is unavailable.")
+		return
+	}
+	timeout := defaultKafkaTimeout
+	if conf.Timeout > 0 {
+		timeout = time.Duration(conf.Timeout) * time.Second
+	}
+	executor.SetProducer(executor.NewKafkaProducer(conf.Brokers, timeout))
+	mainLog.Infof("Kafka producer of brokers %v is ready.", conf.Brokers)
+}
+
 func registers(router *gin.Engine) {
 	router.GET("/about", func(c *gin.Context) {
 		c.JSON(http.StatusOK, config.GetApp())
@@ -41,6 +71,41 @@ func registers(router *gin.Engine) {
 	taskGroup.PUT("/", resources.CreateT)
 	taskGroup.POST("/:id", resources.UpdateT)
 	taskGroup.DELETE("/:id", resources.DeleteT)
+	taskGroup.GET("/:id/records", resources.GetTRecords)
+	taskGroup.POST("/:id/enable", resources.EnableT)
+	taskGroup.POST("/:id/disable", resources.DisableT)
+	taskGroup.POST("/:id/pause", resources.PauseT)
+	taskGroup.POST("/:id/resume", resources.ResumeT)
+	taskGroup.POST("/:id/trigger", resources.TriggerT)
+	taskGroup.GET("/:id/preview", resources.PreviewT)
+
+	router.POST("/v1/delay", resources.CreateD)
+
+	workflowGroup := router.Group("/v1/workflow")
+	workflowGroup.GET("/:id", resources.GetW)
+	workflowGroup.PUT("/", resources.CreateW)
+	workflowGroup.GET("/:id/runs/:runId", resources.GetWRun)
+
+	calendarGroup := router.Group("/v1/calendar")
+	calendarGroup.GET("/:id", resources.GetCal)
+	calendarGroup.PUT("/", resources.CreateCal)
+	calendarGroup.POST("/:id", resources.UpdateCal)
+	calendarGroup.DELETE("/:id", resources.DeleteCal)
+	calendarGroup.POST("/:id/ics", resources.ImportCal)
+
+	taskConfigGroup := router.Group("/v1/task-config")
+	taskConfigGroup.GET("/:id", resources.GetTC)
+	taskConfigGroup.GET("/", resources.GetTCWith)
+	taskConfigGroup.PUT("/", resources.CreateTC)
+	taskConfigGroup.POST("/:id", resources.UpdateTC)
+	taskConfigGroup.DELETE("/:id", resources.DeleteTC)
+
+	recordGroup := router.Group("/v1/scheduling-record")
+	recordGroup.GET("/:id", resources.GetSR)
+	recordGroup.GET("/", resources.GetSRWith)
+	recordGroup.PUT("/", resources.CreateSR)
+	recordGroup.POST("/:id", resources.UpdateSR)
+	recordGroup.DELETE("/:id", resources.DeleteSR)
 }
 
 func init() {
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/galaxy-center/galaxy/cron"
+	"github.com/galaxy-center/galaxy/models/calendar"
+	"github.com/galaxy-center/galaxy/models/task"
+)
+
+// maxExcludedSlots the max number of consecutive fire times skipped by the
+// calendar, the cron is regarded as never firing again beyond it.
+const maxExcludedSlots = 10000
+
+// excluder tells whether a fire time is excluded with the reason, e.g. by
+// the calendar of task.
+type excluder interface {
+	Excluded(t time.Time) (bool, string)
+}
+
+// taskCron the cron of task which is evaluated in its timezone, the fire
+// times excluded by its calendar are skipped.
+type taskCron struct {
+	schedule *cron.Schedule
+	loc      *time.Location
+	// calendar is nil if the task has no calendar.
+	calendar excluder
+}
+
+// Slot a fire time of the cron of task, it's skipped with the reason if it's
+// excluded by the calendar of task.
+type Slot struct {
+	FireAt  time.Time `json:"fire_at"`
+	Skipped bool      `json:"skipped"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// cronOf parses the cron of task with its timezone, and loads its calendar.
+func cronOf(t *task.Task) (*taskCron, error) {
+	loc, err := t.Location()
+	if err != nil {
+		return nil, err
+	}
+	s, err := cron.Parse(t.Cron)
+	if err != nil {
+		return nil, err
+	}
+	c := &taskCron{schedule: s, loc: loc}
+	if t.CalendarID != nil {
+		cal, err := calendar.GetExcludeDeleted(*t.CalendarID)
+		if err != nil {
+			return nil, fmt.Errorf("load calendar %d failed: %v", *t.CalendarID, err)
+		}
+		c.calendar = cal
+	}
+	return c, nil
+}
+
+// Next returns the next fire time after t which is not excluded, zero if the
+// cron never fires again.
+func (c *taskCron) Next(t time.Time) time.Time {
+	next := c.schedule.Next(t.In(c.loc))
+	for i := 0; i < maxExcludedSlots && !next.IsZero(); i++ {
+		if excluded, _ := c.excluded(next); !excluded {
+			return next
+		}
+		next = c.schedule.Next(next)
+	}
+	return time.Time{}
+}
+
+// excluded returns true with the reason if the fire time is excluded.
+func (c *taskCron) excluded(t time.Time) (bool, string) {
+	if c.calendar == nil {
+		return false, ""
+	}
+	return c.calendar.Excluded(t)
+}
+
+// preview returns the next n fire times after t, the excluded ones are
+// included as skipped. It stops once the task would be exhausted by its
+// end_at or max_runs.
+func (c *taskCron) preview(t *task.Task, after time.Time, n int) []Slot {
+	slots := make([]Slot, 0, n)
+	runs := t.Runs
+	for next := c.schedule.Next(after.In(c.loc)); !next.IsZero() && len(slots) < n; next = c.schedule.Next(next) {
+		if t.EndAt > 0 && next.UnixNano() > int64(t.EndAt) {
+			break
+		}
+		excluded, reason := c.excluded(next)
+		if !excluded {
+			if t.MaxRuns > 0 && runs >= t.MaxRuns {
+				break
+			}
+			runs++
+		}
+		slots = append(slots, Slot{FireAt: next, Skipped: excluded, Reason: reason})
+	}
+	return slots
+}
+
+// NextFire returns the next fire time of the cron of task after t, which is
+// evaluated in the timezone of task and out of its calendar, zero if it
+// never fires again.
+func NextFire(t *task.Task, after time.Time) (time.Time, error) {
+	c, err := cronOf(t)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return c.Next(after), nil
+}
+
+// Preview returns the next n fire times of the cron of task after t, the ones
+// excluded by its calendar are included as skipped.
+func Preview(t *task.Task, after time.Time, n int) ([]Slot, error) {
+	c, err := cronOf(t)
+	if err != nil {
+		return nil, err
+	}
+	return c.preview(t, after, n), nil
+}
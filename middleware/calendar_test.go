@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/galaxy-center/galaxy/cron"
+	"github.com/galaxy-center/galaxy/models/calendar"
+	"github.com/galaxy-center/galaxy/models/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func holidays(t *testing.T) (*calendar.Calendar, *time.Location) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	assert.Nil(t, err)
+	window := time.Date(2021, 10, 5, 9, 0, 0, 0, shanghai)
+	return &calendar.Calendar{
+		Name:     "cn",
+		Timezone: "Asia/Shanghai",
+		Exclusions: calendar.Exclusions{
+			Dates: []calendar.Date{{Date: "2021-10-01", Summary: "National Day"}, {Date: "2021-10-02"}},
+			Ranges: []calendar.Range{{
+				Start:   uint64(window.UnixNano()),
+				End:     uint64(window.Add(time.Hour).UnixNano()),
+				Summary: "maintenance",
+			}},
+		},
+	}, shanghai
+}
+
+func TestExcluded(t *testing.T) {
+	cal, shanghai := holidays(t)
+
+	excluded, reason := cal.Excluded(time.Date(2021, 10, 1, 23, 59, 0, 0, shanghai))
+	assert.True(t, excluded)
+	assert.Equal(t, `calendar "cn": National Day`, reason)
+
+	// 2021-10-02 00:30 in Shanghai.
+	excluded, reason = cal.Excluded(time.Date(2021, 10, 1, 16, 30, 0, 0, time.UTC))
+	assert.True(t, excluded, "dates are in the timezone of calendar")
+	assert.Equal(t, `calendar "cn": 2021-10-02`, reason)
+
+	excluded, _ = cal.Excluded(time.Date(2021, 10, 3, 0, 0, 0, 0, shanghai))
+	assert.False(t, excluded)
+
+	excluded, reason = cal.Excluded(time.Date(2021, 10, 5, 9, 0, 0, 0, shanghai))
+	assert.True(t, excluded)
+	assert.Equal(t, `calendar "cn": maintenance`, reason)
+	excluded, _ = cal.Excluded(time.Date(2021, 10, 5, 10, 0, 0, 0, shanghai))
+	assert.False(t, excluded, "the end of range is not excluded")
+}
+
+func TestNextSkipsExcluded(t *testing.T) {
+	cal, shanghai := holidays(t)
+	c := &taskCron{schedule: cron.MustParse("0 0 9 * * *"), loc: shanghai, calendar: cal}
+
+	next := c.Next(time.Date(2021, 9, 30, 10, 0, 0, 0, shanghai))
+	assert.Equal(t, time.Date(2021, 10, 3, 9, 0, 0, 0, shanghai), next)
+	next = c.Next(time.Date(2021, 10, 4, 10, 0, 0, 0, shanghai))
+	assert.Equal(t, time.Date(2021, 10, 6, 9, 0, 0, 0, shanghai), next)
+
+	everyday := &calendar.Calendar{Exclusions: calendar.Exclusions{Ranges: []calendar.Range{{End: ^uint64(0)}}}}
+	c.calendar = everyday
+	assert.True(t, c.Next(next).IsZero(), "never fires again")
+}
+
+func TestPreview(t *testing.T) {
+	cal, shanghai := holidays(t)
+	c := &taskCron{schedule: cron.MustParse("0 0 9 * * *"), loc: shanghai, calendar: cal}
+	tk := &task.Task{MaxRuns: 5, Runs: 2}
+
+	slots := c.preview(tk, time.Date(2021, 9, 30, 10, 0, 0, 0, shanghai), 10)
+	assert.Equal(t, []Slot{
+		{FireAt: time.Date(2021, 10, 1, 9, 0, 0, 0, shanghai), Skipped: true, Reason: `calendar "cn": National Day`},
+		{FireAt: time.Date(2021, 10, 2, 9, 0, 0, 0, shanghai), Skipped: true, Reason: `calendar "cn": 2021-10-02`},
+		{FireAt: time.Date(2021, 10, 3, 9, 0, 0, 0, shanghai)},
+		{FireAt: time.Date(2021, 10, 4, 9, 0, 0, 0, shanghai)},
+		{FireAt: time.Date(2021, 10, 5, 9, 0, 0, 0, shanghai), Skipped: true, Reason: `calendar "cn": maintenance`},
+		{FireAt: time.Date(2021, 10, 6, 9, 0, 0, 0, shanghai)},
+	}, slots, "stops at max_runs")
+
+	slots = c.preview(&task.Task{}, time.Date(2021, 9, 30, 10, 0, 0, 0, shanghai), 2)
+	assert.Len(t, slots, 2)
+}
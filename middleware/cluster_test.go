@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/galaxy-center/galaxy/cluster"
+	db "github.com/galaxy-center/galaxy/lifecycle"
+	migrateProvider "github.com/galaxy-center/galaxy/migrate"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	dbOnce sync.Once
+	// dbErr the reason why the test database is unavailable.
+	dbErr interface{}
+)
+
+// requireDB migrates the test database for the test and drops it after, the
+// test is skipped if the database is unavailable, so that the others of the
+// package can run without it.
+func requireDB(t *testing.T) {
+	dbOnce.Do(func() {
+		defer func() { dbErr = recover() }()
+		db.Init()
+	})
+	if dbErr != nil {
+		t.Skipf("database is unavailable: %v", dbErr)
+	}
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	t.Cleanup(func() { migrateProvider.Drop(m) })
+}
+
+// startNodes runs the members of n nodes in the cluster until the test is
+// over, returns them once one of them is the leader and all of them are in
+// the registry.
+func startNodes(t *testing.T, n int) []member {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var nodes []member
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("node%d", i)
+		m := member{
+			elector:  cluster.NewElector(leaderLease, id, time.Second),
+			registry: cluster.NewRegistry(id, time.Second),
+		}
+		m.elector.Run(ctx)
+		m.registry.Run(ctx)
+		nodes = append(nodes, m)
+	}
+	assert.Eventually(t, func() bool {
+		leaders := 0
+		for _, m := range nodes {
+			_, total, ok := m.registry.Shard()
+			if !ok || total != n {
+				return false
+			}
+			if m.elector.IsLeader() {
+				leaders++
+			}
+		}
+		return leaders == 1
+	}, 5*time.Second, 100*time.Millisecond, "nodes should join the cluster")
+	return nodes
+}
+
+// createExpired inserts an enabled one-shot task which is expired.
+func createExpired(t *testing.T, code string, category task.SchedulingCategory) *task.Task {
+	tk := &task.Task{
+		Name:               "expired",
+		Code:               code,
+		Type:               task.DelayQueue,
+		Status:             task.ENABLED,
+		ExpiredAt:          uint64(time.Now().UnixNano()),
+		Timeout:            3,
+		SchedulingCategory: category,
+		Executor:           task.HTTP,
+	}
+	assert.Nil(t, task.Create(tk))
+	return tk
+}
+
+// countRecords returns the number of records of task by trigger.
+func countRecords(t *testing.T, taskID uint64, trigger schedulingrecord.TriggerType) int64 {
+	var count int64
+	err := db.GetDB().Model(&schedulingrecord.SchedulingRecord{}).
+		Where("task_id = ?", taskID).
+		Where("trigger_type = ?", trigger).
+		Count(&count).Error
+	assert.Nil(t, err)
+	return count
+}
+
+func TestSingletonFiresOnceAcrossNodes(t *testing.T) {
+	requireDB(t)
+	nodes := startNodes(t, 2)
+	tk := createExpired(t, "singleton", task.SINGLETON)
+
+	// both nodes tick the same slot at about the same time.
+	var wg sync.WaitGroup
+	for _, m := range nodes {
+		wg.Add(1)
+		go func(m member) {
+			defer wg.Done()
+			fireAs(context.Background(), m, tk.ID, tk.ExpiredAt)
+		}(m)
+	}
+	wg.Wait()
+	// and again later, e.g. the wheel of a node is behind.
+	for _, m := range nodes {
+		fireAs(context.Background(), m, tk.ID, tk.ExpiredAt)
+	}
+	assert.EqualValues(t, 1, countRecords(t, tk.ID, schedulingrecord.SCHEDULED), "SINGLETON task should be fired exactly once")
+
+	// the one-shot task is fired by the leader from several paths at once,
+	// e.g. its wheel and the catch-up of missed runs.
+	var leader member
+	for _, m := range nodes {
+		if m.elector.IsLeader() {
+			leader = m
+		}
+	}
+	once := createExpired(t, "once", task.SINGLETON)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fireAs(context.Background(), leader, once.ID, once.ExpiredAt)
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, countRecords(t, once.ID, schedulingrecord.SCHEDULED), "one-shot task should be fired exactly once")
+}
+
+func TestMultipleFiresOnEveryNode(t *testing.T) {
+	requireDB(t)
+	nodes := startNodes(t, 2)
+	tk := createExpired(t, "multiple", task.MULTIPLE)
+
+	// the first node fires the slot and advances expired_at before the
+	// wheel of the other one ticks.
+	fireAs(context.Background(), nodes[0], tk.ID, tk.ExpiredAt)
+	fired, err := task.Get(tk.ID)
+	assert.Nil(t, err)
+	assert.NotEqual(t, tk.ExpiredAt, fired.ExpiredAt, "expired_at should be advanced")
+	assert.Equal(t, tk.ExpiredAt, fired.FiredAt)
+
+	fireAs(context.Background(), nodes[1], tk.ID, tk.ExpiredAt)
+	assert.EqualValues(t, 2, countRecords(t, tk.ID, schedulingrecord.SCHEDULED), "every node should dispatch its shard")
+	latest, _ := task.Get(tk.ID)
+	assert.Equal(t, 1, latest.Runs, "the slot should be counted once")
+
+	// the stale slot which is never fired is skipped, e.g. changed by users.
+	stale := createExpired(t, "stale", task.MULTIPLE)
+	err = task.UpdatesFromMap(stale.ID, map[string]interface{}{
+		task.TaskColumns.ExpiredAt: stale.ExpiredAt + uint64(time.Hour),
+	})
+	assert.Nil(t, err)
+	fireAs(context.Background(), nodes[1], stale.ID, stale.ExpiredAt)
+	assert.EqualValues(t, 0, countRecords(t, stale.ID, schedulingrecord.SCHEDULED))
+}
+
+func TestMultipleCaughtUpByEveryShard(t *testing.T) {
+	requireDB(t)
+	nodes := startNodes(t, 2)
+	former := registry
+	registry = nodes[0].registry
+	defer func() { registry = former }()
+
+	tk := createExpired(t, "missed", task.MULTIPLE)
+	catchUp(context.Background(), time.Now().Add(time.Second))
+	assert.EqualValues(t, 2, countRecords(t, tk.ID, schedulingrecord.MISFIRE), "the missed run should be dispatched by every shard")
+}
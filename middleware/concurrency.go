@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/galaxy-center/galaxy/models/task"
+)
+
+// maxQueuedRuns the max number of runs of a task queued by QUEUE, the others
+// are skipped.
+const maxQueuedRuns = 100
+
+var (
+	slotsMu sync.Mutex
+	// slots task id -> the runs of task which take its concurrency.
+	slots = make(map[uint64]*taskSlots)
+	// recordSlots record id -> the slot of run.
+	recordSlots = make(map[uint64]*slot)
+)
+
+// slot of a run which takes the concurrency of task on the node, from the
+// run starts until it's over, includes the delays between retries.
+type slot struct {
+	taskID uint64
+	// ready is closed once the run can execute.
+	ready  chan struct{}
+	cancel context.CancelFunc
+	// replaced the message of the run which is cancelled by a newer one.
+	replaced string
+}
+
+// taskSlots the runs of task on the node.
+type taskSlots struct {
+	active  []*slot
+	waiting []*slot
+}
+
+// reserve takes a slot for the new run of task by its max concurrency and
+// overlap policy on the node, returns the context of the run. The slot is nil if the task
+// is unlimited, the reason is returned instead if the run should be skipped.
+func reserve(ctx context.Context, t *task.Task) (context.Context, *slot, string) {
+	if t.MaxConcurrency <= 0 {
+		return ctx, nil, ""
+	}
+	slotsMu.Lock()
+	defer slotsMu.Unlock()
+
+	ts, ok := slots[t.ID]
+	if !ok {
+		ts = &taskSlots{}
+		slots[t.ID] = ts
+	}
+	policy := overlapPolicy(t)
+	full := len(ts.active) >= t.MaxConcurrency
+	switch {
+	case full && policy == task.OVERLAP_SKIP:
+		return ctx, nil, fmt.Sprintf("concurrency: %d runs are active on the node, skipped by %s", len(ts.active), policy)
+	case full && policy == task.OVERLAP_QUEUE && len(ts.waiting) >= maxQueuedRuns:
+		return ctx, nil, fmt.Sprintf("concurrency: %d runs are queued on the node, skipped by %s", len(ts.waiting), policy)
+	case full && policy == task.OVERLAP_REPLACE:
+		for _, group := range [][]*slot{ts.active, ts.waiting} {
+			for _, s := range group {
+				s.replaced = "concurrency: replaced by a newer run"
+				s.cancel()
+			}
+		}
+		// the active ones are released once they are over.
+		ts.waiting = nil
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	s := &slot{taskID: t.ID, ready: make(chan struct{}), cancel: cancel}
+	if full {
+		ts.waiting = append(ts.waiting, s)
+	} else {
+		ts.active = append(ts.active, s)
+		close(s.ready)
+	}
+	return runCtx, s, ""
+}
+
+// bind associates the slot with the record of run.
+func bind(recordID uint64, s *slot) {
+	if s == nil {
+		return
+	}
+	slotsMu.Lock()
+	recordSlots[recordID] = s
+	slotsMu.Unlock()
+}
+
+// acquire waits until the run of record can execute, and takes a worker of
+// the global pool by the priority of task. Returns false with the message of
+// replacement if it's replaced, or empty if ctx is done.
+func acquire(ctx context.Context, t *task.Task, recordID uint64) (bool, string) {
+	if ctx.Err() != nil {
+		return false, replacedMessage(recordID)
+	}
+	if s := slotOf(recordID); s != nil {
+		select {
+		case <-s.ready:
+		case <-ctx.Done():
+			return false, replacedMessage(recordID)
+		}
+	}
+	if !getWorkers().acquire(ctx, t.Priority) {
+		return false, replacedMessage(recordID)
+	}
+	return true, ""
+}
+
+// releaseWorker returns the worker which is taken by acquire.
+func releaseWorker() {
+	getWorkers().release()
+}
+
+// replacedMessage returns the message if the run of record has been replaced.
+func replacedMessage(recordID uint64) string {
+	slotsMu.Lock()
+	defer slotsMu.Unlock()
+	if s, ok := recordSlots[recordID]; ok {
+		return s.replaced
+	}
+	return ""
+}
+
+// slotOf returns the slot bound to the record of run, nil if there is none.
+func slotOf(recordID uint64) *slot {
+	slotsMu.Lock()
+	defer slotsMu.Unlock()
+	return recordSlots[recordID]
+}
+
+// release frees the slot s of run once it's over, the earliest queued run of
+// the task takes it. It's a no-op if the run has no slot or released, or the
+// record has been bound to another slot, so that a run only frees the one it
+// took.
+func release(recordID uint64, s *slot) {
+	slotsMu.Lock()
+	defer slotsMu.Unlock()
+	if s == nil || recordSlots[recordID] != s {
+		return
+	}
+	delete(recordSlots, recordID)
+	discard(s)
+}
+
+// drop frees the slot which is not bound to a record, e.g. the record can't
+// be inserted.
+func drop(s *slot) {
+	if s == nil {
+		return
+	}
+	slotsMu.Lock()
+	discard(s)
+	slotsMu.Unlock()
+}
+
+// discard frees the slot, must be called with slotsMu held.
+func discard(s *slot) {
+	s.cancel()
+	ts, ok := slots[s.taskID]
+	if !ok {
+		return
+	}
+	active := len(ts.active)
+	ts.active = without(ts.active, s)
+	ts.waiting = without(ts.waiting, s)
+	if len(ts.active) < active && len(ts.waiting) > 0 {
+		next := ts.waiting[0]
+		ts.waiting = ts.waiting[1:]
+		ts.active = append(ts.active, next)
+		close(next.ready)
+	}
+	if len(ts.active) == 0 && len(ts.waiting) == 0 {
+		delete(slots, s.taskID)
+	}
+}
+
+// without returns the slots except s.
+func without(slots []*slot, s *slot) []*slot {
+	for i, v := range slots {
+		if v == s {
+			return append(slots[:i:i], slots[i+1:]...)
+		}
+	}
+	return slots
+}
+
+// overlapPolicy returns the overlap policy of task, default is SKIP.
+func overlapPolicy(t *task.Task) task.OverlapPolicy {
+	if t.OverlapPolicy == "" {
+		return task.OVERLAP_SKIP
+	}
+	return t.OverlapPolicy
+}
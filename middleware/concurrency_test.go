@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/galaxy-center/galaxy/models/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func isReady(s *slot) bool {
+	select {
+	case <-s.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestReserveUnlimited(t *testing.T) {
+	ctx := context.Background()
+	runCtx, s, reason := reserve(ctx, &task.Task{ID: 1})
+	assert.Equal(t, ctx, runCtx)
+	assert.Nil(t, s)
+	assert.Empty(t, reason)
+}
+
+func TestReserveSkip(t *testing.T) {
+	tk := &task.Task{ID: 11, MaxConcurrency: 1}
+	_, first, reason := reserve(context.Background(), tk)
+	assert.Empty(t, reason)
+	assert.True(t, isReady(first))
+	bind(1101, first)
+
+	_, second, reason := reserve(context.Background(), tk)
+	assert.Nil(t, second)
+	assert.Equal(t, "concurrency: 1 runs are active on the node, skipped by SKIP", reason)
+
+	release(1101, &slot{taskID: tk.ID, cancel: func() {}})
+	assert.Contains(t, slots, tk.ID, "only the slot bound to the record is freed")
+	release(1101, first)
+	_, third, reason := reserve(context.Background(), tk)
+	assert.Empty(t, reason)
+	assert.True(t, isReady(third))
+	drop(third)
+	assert.NotContains(t, slots, tk.ID, "freed once all runs are over")
+}
+
+func TestReserveQueue(t *testing.T) {
+	tk := &task.Task{ID: 12, MaxConcurrency: 1, OverlapPolicy: task.OVERLAP_QUEUE}
+	_, first, _ := reserve(context.Background(), tk)
+	bind(1201, first)
+	_, second, reason := reserve(context.Background(), tk)
+	assert.Empty(t, reason)
+	assert.False(t, isReady(second), "queued until the first is over")
+	bind(1202, second)
+
+	release(1201, first)
+	assert.True(t, isReady(second))
+	release(1202, second)
+	assert.NotContains(t, slots, tk.ID)
+}
+
+func TestReserveReplace(t *testing.T) {
+	tk := &task.Task{ID: 13, MaxConcurrency: 1, OverlapPolicy: task.OVERLAP_REPLACE}
+	firstCtx, first, _ := reserve(context.Background(), tk)
+	bind(1301, first)
+	_, second, reason := reserve(context.Background(), tk)
+	assert.Empty(t, reason)
+	bind(1302, second)
+
+	assert.NotNil(t, firstCtx.Err(), "the first is cancelled")
+	assert.Equal(t, "concurrency: replaced by a newer run", replacedMessage(1301))
+	assert.Empty(t, replacedMessage(1302))
+	assert.False(t, isReady(second), "waits for the first to be over")
+
+	ok, replaced := acquire(firstCtx, tk, 1301)
+	assert.False(t, ok)
+	assert.Equal(t, "concurrency: replaced by a newer run", replaced)
+	release(1301, first)
+
+	assert.True(t, isReady(second))
+	ok, _ = acquire(context.Background(), tk, 1302)
+	assert.True(t, ok)
+	releaseWorker()
+	release(1302, second)
+	assert.NotContains(t, slots, tk.ID)
+}
@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/galaxy-center/galaxy/executor"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+	"github.com/galaxy-center/galaxy/utils"
+	"gorm.io/datatypes"
+)
+
+// schedulerName marks the records which are created by scheduler.
+const schedulerName = "scheduler"
+
+// ErrNotStarted returned while triggering before the scheduler started.
+var ErrNotStarted = errors.New("scheduler is not started")
+
+// dispatch creates a scheduling record for the expired task and hands it over
+// to the executor.
+func dispatch(ctx context.Context, t *task.Task, trigger schedulingrecord.TriggerType) {
+	record := &schedulingrecord.SchedulingRecord{
+		TaskID:      t.ID,
+		TriggerType: trigger,
+		CreatedBy:   schedulerName,
+	}
+	if err := start(ctx, t, record); err != nil {
+		schedulerLog.WithField("task", t.ID).Errorf("occurred exception when inserting record: %v", err)
+	}
+}
+
+// skip records a run of task which is not executed with the reason.
+func skip(t *task.Task, trigger schedulingrecord.TriggerType, reason string) *schedulingrecord.SchedulingRecord {
+	record := &schedulingrecord.SchedulingRecord{
+		TaskID:      t.ID,
+		TriggerType: trigger,
+		CreatedBy:   schedulerName,
+	}
+	if err := skipRecord(t, record, reason); err != nil {
+		schedulerLog.WithField("task", t.ID).Errorf("occurred exception when inserting skipped record: %v", err)
+		return nil
+	}
+	return record
+}
+
+// skipRecord inserts the record of a run which is not executed with the
+// reason.
+func skipRecord(t *task.Task, record *schedulingrecord.SchedulingRecord, reason string) error {
+	record.Status = schedulingrecord.SKIPPED
+	record.Attempt = 1
+	record.Message = reason
+	record.UpdatedBy = schedulerName
+	if err := schedulingrecord.Create(record); err != nil {
+		return err
+	}
+	schedulerLog.WithField("task", t.ID).WithField("record", record.ID).Infof("Skipped run: %s", reason)
+	return nil
+}
+
+// Trigger runs the task immediately out of its schedule on current node, the
+// overrides are merged into the content of task config for this run only.
+// Returns the record of the run.
+func Trigger(t *task.Task, createdBy string, overrides datatypes.JSON) (*schedulingrecord.SchedulingRecord, error) {
+	if schedulerCtx == nil {
+		return nil, ErrNotStarted
+	}
+	record := &schedulingrecord.SchedulingRecord{
+		TaskID:      t.ID,
+		TriggerType: schedulingrecord.MANUAL,
+		Overrides:   overrides,
+		CreatedBy:   createdBy,
+	}
+	if err := start(schedulerCtx, t, record); err != nil {
+		return nil, err
+	}
+	schedulerLog.WithField("task", t.ID).WithField("record", record.ID).Infof("Task triggered by %s", createdBy)
+	return record, nil
+}
+
+// start inserts the record of a new run and executes it asynchronously, the
+// root task of workflow begins a new run of the workflow. The run is recorded
+// as SKIPPED if the task has reached its max concurrency.
+func start(ctx context.Context, t *task.Task, record *schedulingrecord.SchedulingRecord) error {
+	began := false
+	if record.WorkflowRunID == nil && t.IsRoot() {
+		if err := beginRun(t, record); err != nil {
+			return err
+		}
+		began = true
+	}
+	runCtx, s, reason := reserve(ctx, t)
+	if reason != "" {
+		if err := skipRecord(t, record, reason); err != nil {
+			return err
+		}
+		advance(ctx, t, record, schedulingrecord.SKIPPED)
+	} else {
+		record.Status = schedulingrecord.NEW
+		record.Attempt = 1
+		record.UpdatedBy = schedulerName
+		if err := schedulingrecord.Create(record); err != nil {
+			drop(s)
+			return err
+		}
+		bind(record.ID, s)
+		if err := updateStatus(record.ID, schedulingrecord.NEW, schedulingrecord.RUNNABLE, nil); err != nil {
+			release(record.ID, s)
+			return err
+		}
+		record.Status = schedulingrecord.RUNNABLE
+		go execute(runCtx, t, record)
+	}
+	if began {
+		startRoots(ctx, t, record)
+	}
+	return nil
+}
+
+// execute runs the task by its executor and records the result, it waits for
+// the concurrency of task and a worker of the node before running.
+func execute(ctx context.Context, t *task.Task, record *schedulingrecord.SchedulingRecord) {
+	s := slotOf(record.ID)
+	if ok, replaced := acquire(ctx, t, record.ID); !ok {
+		if replaced != "" && updateStatus(record.ID, schedulingrecord.RUNNABLE, schedulingrecord.FAILED, withMessage(replaced)) == nil {
+			advance(ctx, t, record, schedulingrecord.FAILED)
+		}
+		release(record.ID, s)
+		return
+	}
+	var deadlineAt uint64
+	if t.Timeout > 0 {
+		deadlineAt = uint64(time.Now().Add(time.Duration(t.Timeout) * time.Second).UnixNano())
+	}
+	values := map[string]interface{}{
+		schedulingrecord.SchedulingRecordColumns.DeadlineAt:    deadlineAt,
+		schedulingrecord.SchedulingRecordColumns.NextAttemptAt: 0,
+	}
+	if err := updateStatus(record.ID, schedulingrecord.RUNNABLE, schedulingrecord.RUNNING, values); err != nil {
+		releaseWorker()
+		release(record.ID, s)
+		return
+	}
+
+	var res executor.Result
+	if conf, err := loadConfig(t, record); err != nil {
+		res = executor.Failed(0, fmt.Sprintf("load task config failed: %v", err))
+	} else {
+		res = executor.Execute(ctx, t, conf)
+	}
+	releaseWorker()
+	if shard, ok := executor.ShardFrom(ctx); ok {
+		res.Message = fmt.Sprintf("shard %d/%d: %s", shard.Index, shard.Total, res.Message)
+	}
+	replaced := replacedMessage(record.ID)
+	if replaced != "" && res.Status != schedulingrecord.FINISHED {
+		res = executor.Failed(0, replaced)
+	} else if res.Status != schedulingrecord.FINISHED && retry(ctx, t, record, res) {
+		// the slot is kept until the retries are over.
+		return
+	}
+	release(record.ID, s)
+	if updateStatus(record.ID, schedulingrecord.RUNNING, res.Status, withMessage(res.Message)) == nil {
+		cleanup(t, record)
+		advance(ctx, t, record, res.Status)
+	}
+	schedulerLog.WithField("task", t.ID).WithField("record", record.ID).Debugf("task executed: %s", res.Status)
+}
+
+// cleanup deletes the one-shot DelayQueue task softly once its run is over,
+// the code is kept until purged, so that it can't be created again within
+// the retention. The nodes of workflow are kept for the later runs.
+func cleanup(t *task.Task, record *schedulingrecord.SchedulingRecord) {
+	if t.Type != task.DelayQueue || t.Cron != "" || record.TriggerType == schedulingrecord.MANUAL || t.WorkflowID != nil {
+		return
+	}
+	if err := task.DeleteAt(t.ID); err != nil {
+		schedulerLog.WithField("task", t.ID).Errorf("occurred exception when cleaning delivered task: %v", err)
+	}
+}
+
+// withMessage returns the values to update the message of record.
+func withMessage(message string) map[string]interface{} {
+	return map[string]interface{}{
+		schedulingrecord.SchedulingRecordColumns.Message: message,
+	}
+}
+
+// updateStatus moves the record from status to another one with extra values,
+// fails if the record has been moved by others meanwhile, e.g. the watchdog.
+func updateStatus(id uint64, from, to schedulingrecord.Status, values map[string]interface{}) error {
+	if values == nil {
+		values = make(map[string]interface{}, 1)
+	}
+	values[schedulingrecord.SchedulingRecordColumns.UpdatedBy] = schedulerName
+
+	err := schedulingrecord.TransitionWith(id, from, to, values)
+	if errors.Is(err, schedulingrecord.ErrLostTransition) {
+		schedulerLog.WithField("record", id).Warnf("record has been moved from %s by others, skipped moving to %s", from, to)
+	} else if err != nil {
+		schedulerLog.WithField("record", id).Errorf("occurred exception when updating record from %s to %s: %v", from, to, err)
+	}
+	return err
+}
+
+// loadConfig returns the config of task which is needed by the executor, with
+// the overrides of record merged into the content.
+func loadConfig(t *task.Task, record *schedulingrecord.SchedulingRecord) (*taskconfig.TaskConfig, error) {
+	var conf *taskconfig.TaskConfig
+	if t.TaskConfigID != nil {
+		c, err := taskconfig.GetExcludeDeleted(*t.TaskConfigID)
+		if err != nil {
+			return nil, err
+		}
+		conf = c
+	}
+	if len(record.Overrides) == 0 {
+		return conf, nil
+	}
+	if conf == nil {
+		conf = &taskconfig.TaskConfig{}
+	}
+	content, err := utils.MergeJSON(conf.Content, record.Overrides)
+	if err != nil {
+		return nil, fmt.Errorf("merge overrides failed: %v", err)
+	}
+	conf.Content = content
+	return conf, nil
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"testing"
+
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+func TestLoadConfigOverrides(t *testing.T) {
+	record := &schedulingrecord.SchedulingRecord{TriggerType: schedulingrecord.SCHEDULED}
+	conf, err := loadConfig(&task.Task{}, record)
+	assert.Nil(t, err)
+	assert.Nil(t, conf, "no config without overrides")
+
+	record = &schedulingrecord.SchedulingRecord{
+		TriggerType: schedulingrecord.MANUAL,
+		Overrides:   datatypes.JSON(`{"url":"http://localhost","body":{"id":1}}`),
+	}
+	conf, err = loadConfig(&task.Task{}, record)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"url":"http://localhost","body":{"id":1}}`, string(conf.Content))
+
+	record.Overrides = datatypes.JSON(`[`)
+	_, err = loadConfig(&task.Task{}, record)
+	assert.NotNil(t, err)
+}
+
+func TestRunnable(t *testing.T) {
+	scheduled := &schedulingrecord.SchedulingRecord{TriggerType: schedulingrecord.SCHEDULED}
+	manual := &schedulingrecord.SchedulingRecord{TriggerType: schedulingrecord.MANUAL}
+
+	assert.False(t, runnable(nil, manual))
+	assert.True(t, runnable(&task.Task{Status: task.ENABLED}, scheduled))
+	assert.False(t, runnable(&task.Task{Status: task.PAUSED}, scheduled))
+	assert.True(t, runnable(&task.Task{Status: task.PAUSED}, manual))
+}
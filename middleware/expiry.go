@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/galaxy-center/galaxy/models/task"
+)
+
+// exhausted returns the reason if the recurring task should not fire at next
+// after it has fired runs times, next is zero if the cron never fires again.
+func exhausted(t *task.Task, runs int, next time.Time) string {
+	switch {
+	case t.MaxRuns > 0 && runs >= t.MaxRuns:
+		return fmt.Sprintf("max_runs %d reached", t.MaxRuns)
+	case t.EndAt > 0 && (next.IsZero() || next.UnixNano() > int64(t.EndAt)):
+		return fmt.Sprintf("end_at %s reached", time.Unix(0, int64(t.EndAt)).UTC().Format(time.RFC3339))
+	case next.IsZero():
+		return "cron never fires again"
+	}
+	return ""
+}
+
+// disable moves the exhausted task to DISABLED with the reason, and removes
+// it from the time wheel.
+func disable(t *task.Task, reason string) {
+	Unschedule(t.ID)
+	values := map[string]interface{}{
+		task.TaskColumns.DisabledReason: reason,
+		task.TaskColumns.UpdatedBy:      schedulerName,
+	}
+	err := task.Transition(t.ID, task.ENABLED, task.DISABLED, values)
+	if errors.Is(err, task.ErrLostTransition) {
+		schedulerLog.WithField("id", t.ID).Debug("exhausted task has been changed by others")
+		return
+	}
+	if err != nil {
+		schedulerLog.WithField("id", t.ID).Errorf("occurred exception when disabling exhausted task: %v", err)
+		return
+	}
+	schedulerLog.WithField("id", t.ID).Infof("Disabled task: %s", reason)
+}
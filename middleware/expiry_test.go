@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/galaxy-center/galaxy/models/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExhausted(t *testing.T) {
+	next := time.Date(2021, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	unlimited := &task.Task{}
+	assert.Empty(t, exhausted(unlimited, 1000, next))
+	assert.Equal(t, "cron never fires again", exhausted(unlimited, 0, time.Time{}))
+
+	limited := &task.Task{MaxRuns: 3}
+	assert.Empty(t, exhausted(limited, 2, next))
+	assert.Equal(t, "max_runs 3 reached", exhausted(limited, 3, next))
+
+	ended := &task.Task{EndAt: uint64(next.UnixNano())}
+	assert.Empty(t, exhausted(ended, 0, next), "fires at end_at")
+	assert.Equal(t, "end_at 2021-01-01T08:00:00Z reached", exhausted(ended, 0, next.Add(time.Second)))
+	assert.Equal(t, "end_at 2021-01-01T08:00:00Z reached", exhausted(ended, 0, time.Time{}))
+}
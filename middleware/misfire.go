@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/galaxy-center/galaxy/executor"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+)
+
+const (
+	// misfireThreshold the task is missed if it has not been fired after its
+	// expired_at for so long, e.g. the nodes were down or the leader changed.
+	misfireThreshold = time.Minute
+	// misfireBatch the max number of missed tasks caught up at a time.
+	misfireBatch = 100
+	// maxMisfireRuns the max number of runs fired for a missed task by
+	// FIRE_ALL, the rest are skipped.
+	maxMisfireRuns = 10
+	// maxMisfireScan the max number of missed fire times counted by cron.
+	maxMisfireScan = 100000
+)
+
+// missed describes the runs of a task which were missed before now.
+type missed struct {
+	// count the number of missed runs, it's at least maxMisfireScan if capped.
+	count  int
+	capped bool
+	first  time.Time
+	last   time.Time
+	// next the next fire time after now, zero for one-shot task or the cron
+	// never fires again.
+	next time.Time
+}
+
+// catchUp applies the misfire policy to the enabled tasks which have been
+// missed before the time, it only runs on the leader.
+func catchUp(ctx context.Context, before time.Time) {
+	tasks, err := task.GetEnabledOverdue(uint64(before.UnixNano()), misfireBatch)
+	if err != nil {
+		schedulerLog.Errorf("occurred exception when loading missed tasks: %v", err)
+		return
+	}
+	now := time.Now()
+	for i := range tasks {
+		t := &tasks[i]
+		if reason := exhausted(t, t.Runs, time.Unix(0, int64(t.ExpiredAt))); recurring(t) && reason != "" {
+			disable(t, reason)
+			continue
+		}
+		m, err := missedRuns(t, now)
+		if err != nil {
+			schedulerLog.WithField("id", t.ID).Warnf("skipped catching up task with invalid cron, timezone or calendar: %v", err)
+			continue
+		}
+		fired := firedRuns(misfirePolicy(t), m.count)
+		if left := t.MaxRuns - t.Runs; t.MaxRuns > 0 && fired > left {
+			fired = 0
+			if left > 0 {
+				fired = left
+			}
+		}
+		var reason string
+		if recurring(t) {
+			reason = exhausted(t, t.Runs+fired, m.next)
+		}
+		var next uint64
+		if !m.next.IsZero() && reason == "" {
+			next = uint64(m.next.UnixNano())
+		}
+		// claims the missed runs, so that they are caught up only once.
+		ok, err := task.Fire(t.ID, t.ExpiredAt, next, fired)
+		if err != nil {
+			schedulerLog.WithField("id", t.ID).Errorf("occurred exception when catching up task: %v", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		misfire(ctx, t, m, fired)
+
+		if reason != "" {
+			disable(t, reason)
+		} else if next > 0 {
+			rescheduled := *t
+			rescheduled.ExpiredAt = next
+			scheduleWithinHorizon(ctx, &rescheduled)
+		}
+	}
+}
+
+// misfire fires the number of the missed runs of task and skips the others
+// by its misfire policy and max_runs, the decision is kept in the records
+// triggered by MISFIRE. Every missed run of MULTIPLE task is dispatched by
+// one shard per live node, as the nodes do for the run fired on time.
+func misfire(ctx context.Context, t *task.Task, m missed, fired int) {
+	policy := misfirePolicy(t)
+	schedulerLog.WithField("id", t.ID).Warnf("Caught up task missed %d runs by %s, fired %d", m.count, policy, fired)
+
+	if skipped := m.count - fired; skipped > 0 {
+		more := ""
+		if m.capped {
+			more = "+"
+		}
+		record := skip(t, schedulingrecord.MISFIRE, fmt.Sprintf("misfire: skipped %d%s runs due between %s and %s by %s",
+			skipped, more, m.first.Format(time.RFC3339), m.last.Format(time.RFC3339), policy))
+		if fired == 0 && record != nil {
+			cleanup(t, record)
+		}
+	}
+	if t.SchedulingCategory != task.MULTIPLE {
+		for i := 0; i < fired; i++ {
+			dispatch(ctx, t, schedulingrecord.MISFIRE)
+		}
+		return
+	}
+	total := 1
+	if registry != nil {
+		if n := len(registry.Nodes()); n > 0 {
+			total = n
+		}
+	}
+	for i := 0; i < fired; i++ {
+		for index := 0; index < total; index++ {
+			dispatch(executor.WithShard(ctx, executor.Shard{Index: index, Total: total}), t, schedulingrecord.MISFIRE)
+		}
+	}
+}
+
+// firedRuns returns how many of the missed runs are fired by policy.
+func firedRuns(policy task.MisfirePolicy, count int) int {
+	switch policy {
+	case task.SKIP:
+		return 0
+	case task.FIRE_ALL:
+		if count > maxMisfireRuns {
+			return maxMisfireRuns
+		}
+		return count
+	default:
+		return 1
+	}
+}
+
+// missedRuns counts the fire times of task from its expired_at to now, the
+// ones after its end_at or excluded by its calendar are left out.
+func missedRuns(t *task.Task, now time.Time) (missed, error) {
+	first := time.Unix(0, int64(t.ExpiredAt))
+	m := missed{count: 1, first: first, last: first}
+	if !recurring(t) {
+		return m, nil
+	}
+	c, err := cronOf(t)
+	if err != nil {
+		return m, err
+	}
+	for next := c.Next(first); !next.IsZero() && next.Before(now); next = c.Next(next) {
+		if t.EndAt > 0 && next.UnixNano() > int64(t.EndAt) {
+			break
+		}
+		if m.count >= maxMisfireScan {
+			m.capped = true
+			break
+		}
+		m.count++
+		m.last = next
+	}
+	m.next = c.Next(now)
+	return m, nil
+}
+
+// misfirePolicy returns the misfire policy of task, default is FIRE_ONCE.
+func misfirePolicy(t *task.Task) task.MisfirePolicy {
+	if t.MisfirePolicy == "" {
+		return task.FIRE_ONCE
+	}
+	return t.MisfirePolicy
+}
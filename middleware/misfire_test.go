@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/galaxy-center/galaxy/models/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissedRuns(t *testing.T) {
+	now := time.Date(2021, 1, 1, 10, 30, 30, 0, time.Local)
+	expiredAt := time.Date(2021, 1, 1, 8, 0, 0, 0, time.Local)
+
+	hourly := &task.Task{Type: task.DelayJob, Cron: "0 * * * *", ExpiredAt: uint64(expiredAt.UnixNano())}
+	m, err := missedRuns(hourly, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, m.count, "08:00, 09:00 and 10:00 are missed")
+	assert.False(t, m.capped)
+	assert.Equal(t, expiredAt, m.first)
+	assert.Equal(t, time.Date(2021, 1, 1, 10, 0, 0, 0, time.Local), m.last)
+	assert.Equal(t, time.Date(2021, 1, 1, 11, 0, 0, 0, time.Local), m.next)
+
+	everySecond := &task.Task{Type: task.DelayJob, Cron: "* * * * * *", ExpiredAt: uint64(now.AddDate(0, -1, 0).UnixNano())}
+	m, err = missedRuns(everySecond, now)
+	assert.Nil(t, err)
+	assert.Equal(t, maxMisfireScan, m.count)
+	assert.True(t, m.capped)
+
+	oneShot := &task.Task{Type: task.DelayQueue, ExpiredAt: uint64(expiredAt.UnixNano())}
+	m, err = missedRuns(oneShot, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, m.count)
+	assert.True(t, m.next.IsZero(), "one-shot task has no next run")
+
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	assert.Nil(t, err)
+	nine := time.Date(2021, 1, 1, 9, 0, 0, 0, shanghai)
+	daily := &task.Task{Type: task.DelayJob, Cron: "0 9 * * *", Timezone: "Asia/Shanghai", ExpiredAt: uint64(nine.UnixNano())}
+	m, err = missedRuns(daily, nine.AddDate(0, 0, 2).Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, m.count, "09:00 of 3 days in Asia/Shanghai are missed")
+	assert.True(t, nine.AddDate(0, 0, 3).Equal(m.next))
+
+	unknown := &task.Task{Type: task.DelayJob, Cron: "0 9 * * *", Timezone: "Mars/Olympus", ExpiredAt: uint64(expiredAt.UnixNano())}
+	_, err = missedRuns(unknown, now)
+	assert.NotNil(t, err)
+
+	invalid := &task.Task{Type: task.DelayJob, Cron: "invalid", ExpiredAt: uint64(expiredAt.UnixNano())}
+	_, err = missedRuns(invalid, now)
+	assert.NotNil(t, err)
+}
+
+func TestFiredRuns(t *testing.T) {
+	assert.Equal(t, 1, firedRuns(task.FIRE_ONCE, 3))
+	assert.Equal(t, 1, firedRuns("", 3), "default is FIRE_ONCE")
+	assert.Equal(t, 3, firedRuns(task.FIRE_ALL, 3))
+	assert.Equal(t, maxMisfireRuns, firedRuns(task.FIRE_ALL, maxMisfireRuns+5))
+	assert.Equal(t, 0, firedRuns(task.SKIP, 3))
+}
+
+func TestMissedRunsUntilEndAt(t *testing.T) {
+	now := time.Date(2021, 1, 1, 10, 30, 30, 0, time.Local)
+	expiredAt := time.Date(2021, 1, 1, 8, 0, 0, 0, time.Local)
+	endAt := time.Date(2021, 1, 1, 9, 0, 0, 0, time.Local)
+
+	hourly := &task.Task{Type: task.DelayJob, Cron: "0 * * * *", ExpiredAt: uint64(expiredAt.UnixNano()), EndAt: uint64(endAt.UnixNano())}
+	m, err := missedRuns(hourly, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, m.count, "10:00 is after end_at")
+	assert.Equal(t, endAt, m.last)
+	assert.Equal(t, "end_at "+endAt.UTC().Format(time.RFC3339)+" reached", exhausted(hourly, 2, m.next))
+}
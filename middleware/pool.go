@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/galaxy-center/galaxy/config"
+	"github.com/galaxy-center/galaxy/models/task"
+)
+
+const (
+	// defaultWorkers is used while config.ExecutorConfig#Workers is absent.
+	defaultWorkers = 100
+	// priorityAging the waiting run is raised one priority level every so
+	// long, so that the runs of low priority are not starved.
+	priorityAging = 10 * time.Second
+	// reservedRatio one of so many workers is reserved for the runs above
+	// the default priority.
+	reservedRatio = 10
+)
+
+var (
+	workersOnce sync.Once
+	// workers the global pool of node, a run takes a worker while executing.
+	workers *pool
+)
+
+// pool hands out its workers to the waiting runs by priority, the higher
+// first, the earlier first if they are equal. A few workers are reserved for
+// the runs above the default priority, so that they don't wait for the busy
+// runs of the default one even though the pool is not saturated yet.
+type pool struct {
+	mu   sync.Mutex
+	size int
+	// reserved the number of workers which are only taken by the runs above
+	// the default priority.
+	reserved int
+	busy     int
+	seq      uint64
+	waiting  []*waiter
+	// now returns the current time, it's replaced by tests.
+	now func() time.Time
+}
+
+// waiter a run waiting for a worker.
+type waiter struct {
+	priority int
+	since    time.Time
+	seq      uint64
+	// ready is closed once the worker is taken.
+	ready chan struct{}
+}
+
+// newPool returns a pool of size workers.
+func newPool(size int) *pool {
+	return &pool{size: size, reserved: size / reservedRatio, now: time.Now}
+}
+
+// acquire waits for a worker by priority until ctx is done, returns false if
+// ctx is done before then.
+func (p *pool) acquire(ctx context.Context, priority int) bool {
+	p.mu.Lock()
+	if p.busy < p.limit(priority) && len(p.waiting) == 0 {
+		p.busy++
+		p.mu.Unlock()
+		return true
+	}
+	p.seq++
+	w := &waiter{priority: priority, since: p.now(), seq: p.seq, ready: make(chan struct{})}
+	p.waiting = append(p.waiting, w)
+	// the reserved workers may be free for it.
+	p.handOver()
+	p.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return true
+	case <-ctx.Done():
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		select {
+		case <-w.ready:
+			// taken meanwhile, hands it over to others.
+			p.busy--
+			p.handOver()
+		default:
+			p.waiting = withoutWaiter(p.waiting, w)
+		}
+		return false
+	}
+}
+
+// release returns the worker, the waiting run of the highest effective
+// priority takes it.
+func (p *pool) release() {
+	p.mu.Lock()
+	p.busy--
+	p.handOver()
+	p.mu.Unlock()
+}
+
+// handOver gives the free workers to the waiting runs, must be called with
+// mu held.
+func (p *pool) handOver() {
+	now := p.now()
+	for len(p.waiting) > 0 {
+		next := 0
+		for i, w := range p.waiting[1:] {
+			if p.before(w, p.waiting[next], now) {
+				next = i + 1
+			}
+		}
+		w := p.waiting[next]
+		if p.busy >= p.limit(effectivePriority(w, now)) {
+			// the others are not prior to it.
+			return
+		}
+		p.waiting = append(p.waiting[:next], p.waiting[next+1:]...)
+		p.busy++
+		close(w.ready)
+	}
+}
+
+// limit returns the number of workers which can be taken by the runs of
+// priority, the reserved ones are left out for the default priority.
+func (p *pool) limit(priority int) int {
+	if priority > task.MinPriority {
+		return p.size
+	}
+	return p.size - p.reserved
+}
+
+// before returns true if a takes the worker before b.
+func (p *pool) before(a, b *waiter, now time.Time) bool {
+	pa, pb := effectivePriority(a, now), effectivePriority(b, now)
+	if pa != pb {
+		return pa > pb
+	}
+	return a.seq < b.seq
+}
+
+// effectivePriority returns the priority of waiter which is raised by aging.
+func effectivePriority(w *waiter, now time.Time) int {
+	return w.priority + int(now.Sub(w.since)/priorityAging)
+}
+
+// withoutWaiter returns the waiters except w.
+func withoutWaiter(waiting []*waiter, w *waiter) []*waiter {
+	for i, v := range waiting {
+		if v == w {
+			return append(waiting[:i:i], waiting[i+1:]...)
+		}
+	}
+	return waiting
+}
+
+// getWorkers returns the global pool which is sized by config.
+func getWorkers() *pool {
+	workersOnce.Do(func() {
+		n := config.Global().Executor.Workers
+		if n <= 0 {
+			n = defaultWorkers
+		}
+		workers = newPool(n)
+	})
+	return workers
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitFor starts acquiring a worker of priority, and waits until it's queued.
+func waitFor(p *pool, priority int, taken chan<- int) {
+	p.mu.Lock()
+	n := len(p.waiting)
+	p.mu.Unlock()
+	go func() {
+		if p.acquire(context.Background(), priority) {
+			taken <- priority
+		}
+	}()
+	for {
+		p.mu.Lock()
+		queued := len(p.waiting) > n
+		p.mu.Unlock()
+		if queued {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolPriority(t *testing.T) {
+	p := newPool(1)
+	assert.True(t, p.acquire(context.Background(), 0))
+
+	taken := make(chan int, 3)
+	waitFor(p, 1, taken)
+	waitFor(p, 5, taken)
+	waitFor(p, 3, taken)
+
+	for _, want := range []int{5, 3, 1} {
+		p.release()
+		assert.Equal(t, want, <-taken)
+	}
+	p.release()
+	assert.Equal(t, 0, p.busy)
+}
+
+func TestPoolAging(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := newPool(1)
+	p.now = func() time.Time { return now }
+	assert.True(t, p.acquire(context.Background(), 0))
+
+	taken := make(chan int, 2)
+	waitFor(p, 0, taken)
+	now = now.Add(5 * priorityAging)
+	waitFor(p, 4, taken)
+
+	p.release()
+	assert.Equal(t, 0, <-taken, "raised to 5 after waiting, beyond 4")
+	p.release()
+	assert.Equal(t, 4, <-taken)
+}
+
+func TestPoolCancel(t *testing.T) {
+	p := newPool(1)
+	assert.True(t, p.acquire(context.Background(), 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, p.acquire(ctx, 9))
+	assert.Empty(t, p.waiting)
+
+	p.release()
+	assert.True(t, p.acquire(context.Background(), 0), "the worker is free")
+}
+
+func TestPoolReserve(t *testing.T) {
+	p := newPool(10)
+	for i := 0; i < 9; i++ {
+		assert.True(t, p.acquire(context.Background(), 0))
+	}
+
+	taken := make(chan int, 2)
+	waitFor(p, 0, taken)
+	assert.True(t, p.acquire(context.Background(), 1), "the reserved worker is taken by higher priority")
+
+	p.release()
+	p.mu.Lock()
+	assert.Len(t, p.waiting, 1, "the reserved worker should not be taken by the default priority")
+	p.mu.Unlock()
+	p.release()
+	assert.Equal(t, 0, <-taken)
+}
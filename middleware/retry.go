@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/galaxy-center/galaxy/config"
+	"github.com/galaxy-center/galaxy/executor"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+)
+
+// retry re-enqueues the RUNNING record onto the time wheel if the retry
+// policy of task allows, returns false if the attempts are exhausted. The
+// time of next attempt and current node are kept in the record, so that the
+// leader resumes it once the node is not alive, e.g. it crashed.
+func retry(ctx context.Context, t *task.Task, record *schedulingrecord.SchedulingRecord, res executor.Result) bool {
+	p := t.RetryPolicy
+	if record.Attempt >= p.MaxAttempts || !retryable(p, res.Code) {
+		return false
+	}
+
+	s := slotOf(record.ID)
+	delay := retryDelay(p, record.Attempt, rand.Float64)
+	values := map[string]interface{}{
+		schedulingrecord.SchedulingRecordColumns.Attempt:       record.Attempt + 1,
+		schedulingrecord.SchedulingRecordColumns.Message:       res.Message,
+		schedulingrecord.SchedulingRecordColumns.NextAttemptAt: uint64(time.Now().Add(delay).UnixNano()),
+		schedulingrecord.SchedulingRecordColumns.NodeID:        config.GetNodeID(),
+	}
+	if err := updateStatus(record.ID, schedulingrecord.RUNNING, schedulingrecord.RUNNABLE, values); err != nil {
+		// the record is moved by others, or left to the watchdog.
+		release(record.ID, s)
+		return true
+	}
+	record.Attempt++
+
+	go func(expired <-chan struct{}) {
+		select {
+		case <-ctx.Done():
+			if replaced := replacedMessage(record.ID); replaced != "" {
+				if updateStatus(record.ID, schedulingrecord.RUNNABLE, schedulingrecord.FAILED, withMessage(replaced)) == nil {
+					advance(ctx, t, record, schedulingrecord.FAILED)
+				}
+			}
+			// otherwise it's resumed by the leader once it's due.
+			release(record.ID, s)
+		case <-expired:
+			resume(ctx, t, record)
+		}
+	}(tw.After(delay))
+	schedulerLog.WithField("record", record.ID).Debugf("retry attempt %d after %s", record.Attempt, delay)
+	return true
+}
+
+// resume executes the pending retry of the RUNNABLE record with the latest
+// task, the record is FAILED if the task is unavailable.
+func resume(ctx context.Context, t *task.Task, record *schedulingrecord.SchedulingRecord) {
+	latest, err := task.GetExcludeDeleted(t.ID)
+	if err != nil || !runnable(latest, record) {
+		release(record.ID, slotOf(record.ID))
+		if updateStatus(record.ID, schedulingrecord.RUNNABLE, schedulingrecord.FAILED, withMessage("task is unavailable before retrying")) == nil {
+			advance(ctx, t, record, schedulingrecord.FAILED)
+		}
+		return
+	}
+	execute(ctx, latest, record)
+}
+
+// runnable returns true if the run of record can go on with the task, the
+// manual triggered run ignores the status of task.
+func runnable(t *task.Task, record *schedulingrecord.SchedulingRecord) bool {
+	if t == nil {
+		return false
+	}
+	return t.Status == task.ENABLED || record.TriggerType == schedulingrecord.MANUAL
+}
+
+// retryable returns true if the failure responded code can be retried, the
+// failure without response always can be retried.
+func retryable(p task.RetryPolicy, code int) bool {
+	if code == 0 {
+		return true
+	}
+	codes, err := p.ParseStatusCodes()
+	if err != nil || len(codes) == 0 {
+		return true
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay returns the delay before the next attempt, random returns a
+// number in [0, 1) to apply the jitter.
+func retryDelay(p task.RetryPolicy, attempt int, random func() float64) time.Duration {
+	delay := time.Duration(p.Delay) * time.Second
+	if p.Backoff == task.EXPONENTIAL {
+		// stops doubling beyond horizon to avoid overflow.
+		for i := 1; i < attempt && delay < horizon; i++ {
+			delay *= 2
+		}
+	}
+	if maxDelay := time.Duration(p.MaxDelay) * time.Second; maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+	if p.Jitter > 0 {
+		delay = time.Duration(float64(delay) * (1 + p.Jitter*(2*random()-1)))
+	}
+	// the time wheel can't hold the delay beyond horizon.
+	if delay > horizon {
+		delay = horizon
+	}
+	return delay
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/galaxy-center/galaxy/config"
+	"github.com/galaxy-center/galaxy/models/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	config.SetTestMode(true)
+}
+
+func TestRetryDelay(t *testing.T) {
+	none := func() float64 { return 0.5 }
+
+	fixed := task.RetryPolicy{Backoff: task.FIXED, Delay: 10}
+	assert.Equal(t, 10*time.Second, retryDelay(fixed, 1, none))
+	assert.Equal(t, 10*time.Second, retryDelay(fixed, 5, none))
+
+	exponential := task.RetryPolicy{Backoff: task.EXPONENTIAL, Delay: 10, MaxDelay: 60}
+	assert.Equal(t, 10*time.Second, retryDelay(exponential, 1, none))
+	assert.Equal(t, 20*time.Second, retryDelay(exponential, 2, none))
+	assert.Equal(t, 40*time.Second, retryDelay(exponential, 3, none))
+	assert.Equal(t, 60*time.Second, retryDelay(exponential, 4, none), "should be limited by max delay")
+
+	unlimited := task.RetryPolicy{Backoff: task.EXPONENTIAL, Delay: 10}
+	assert.Equal(t, horizon, retryDelay(unlimited, 100, none), "should be limited by horizon")
+}
+
+func TestRetryDelayJitter(t *testing.T) {
+	p := task.RetryPolicy{Backoff: task.FIXED, Delay: 10, Jitter: 0.2}
+	assert.Equal(t, 8*time.Second, retryDelay(p, 1, func() float64 { return 0 }))
+	assert.Equal(t, 12*time.Second, retryDelay(p, 1, func() float64 { return 1 }))
+}
+
+func TestRetryable(t *testing.T) {
+	any := task.RetryPolicy{}
+	assert.True(t, retryable(any, 0))
+	assert.True(t, retryable(any, 500))
+	assert.True(t, retryable(any, 404))
+
+	some := task.RetryPolicy{StatusCodes: "502, 503"}
+	assert.True(t, retryable(some, 0), "failure without response should be retried")
+	assert.True(t, retryable(some, 503))
+	assert.False(t, retryable(some, 500))
+}
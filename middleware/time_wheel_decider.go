@@ -1,13 +1,287 @@
 package middleware
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"github.com/funkygao/golib/timewheel"
+	"github.com/galaxy-center/galaxy/cluster"
+	"github.com/galaxy-center/galaxy/config"
+	"github.com/galaxy-center/galaxy/executor"
+	logger "github.com/galaxy-center/galaxy/log"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
 )
 
-var tw = timewheel.NewTimeWheel(1*time.Second, 35*60)
+const (
+	// horizon how far ahead the tasks are loaded onto the time wheel,
+	// must be less than the max timeout of the wheel (35 minutes).
+	horizon = 30 * time.Minute
+	// refillInterval how often the time wheel is refilled from db.
+	refillInterval = time.Minute
+	// leaderLease the lease name of the node which dispatches SINGLETON tasks.
+	leaderLease = "scheduler"
+)
+
+var (
+	log          = logger.Get()
+	schedulerLog = log.WithField("prefix", "scheduler")
+
+	tw = timewheel.NewTimeWheel(1*time.Second, 35*60)
+
+	scheduledMu sync.Mutex
+	// scheduled caches task id -> entry which are waiting on the time wheel.
+	scheduled = make(map[uint64]entry)
+	// schedulerCtx is set by Start, tasks are placed onto the time wheel with it.
+	schedulerCtx context.Context
+
+	// elector only the leader dispatches SINGLETON tasks.
+	elector *cluster.Elector
+	// registry every live node dispatches a shard of MULTIPLE tasks.
+	registry *cluster.Registry
+)
+
+// entry of a task waiting on the time wheel.
+type entry struct {
+	expiredAt uint64
+	cancel    context.CancelFunc
+}
+
+// Start loads the upcoming tasks onto the time wheel and refills it
+// periodically until ctx is done.
+func Start(ctx context.Context) {
+	schedulerCtx = ctx
+	elector = cluster.NewElector(leaderLease, config.GetNodeID(), cluster.LeaseTTL())
+	elector.Run(ctx)
+	registry = cluster.NewRegistry(config.GetNodeID(), cluster.LeaseTTL())
+	registry.Run(ctx)
+	startWatchdog(ctx)
+
+	lastRefill := time.Now()
+	refill(ctx, lastRefill, lastRefill.Add(horizon))
+	if elector.IsLeader() {
+		catchUp(ctx, lastRefill)
+	}
+
+	go func(ctx context.Context) {
+		ticker := time.NewTicker(refillInterval)
+
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				schedulerLog.Debug("Stopping the scheduler")
+				return
+
+			case now := <-ticker.C:
+				// starts from the last refill time, so the tasks which were
+				// created or updated since then will not be missed.
+				refill(ctx, lastRefill, now.Add(horizon))
+				lastRefill = now
+				if elector.IsLeader() {
+					catchUp(ctx, now.Add(-misfireThreshold))
+				}
+			}
+		}
+	}(ctx)
+	schedulerLog.Info("Scheduler started")
+}
+
+// refill places the tasks whose expired_at falls into [from, to) onto the time wheel.
+func refill(ctx context.Context, from, to time.Time) {
+	tasks, err := task.GetEnabledBetween(uint64(from.UnixNano()), uint64(to.UnixNano()))
+	if err != nil {
+		schedulerLog.Errorf("occurred exception when loading tasks: %v", err)
+		return
+	}
+	for i := range tasks {
+		schedule(ctx, &tasks[i])
+	}
+}
+
+// Schedule places the task onto the time wheel at once if it's enabled and
+// falls into the horizon, it's a no-op before Start.
+func Schedule(t *task.Task) {
+	if schedulerCtx == nil || t.Status != task.ENABLED {
+		return
+	}
+	scheduleWithinHorizon(schedulerCtx, t)
+}
+
+// Unschedule removes the task from the time wheel of current node at once,
+// other nodes will skip it when it expired.
+func Unschedule(id uint64) {
+	scheduledMu.Lock()
+	if e, ok := scheduled[id]; ok {
+		e.cancel()
+		delete(scheduled, id)
+	}
+	scheduledMu.Unlock()
+}
+
+// schedule places a single task onto the time wheel, the task which has been
+// scheduled with the same expired_at will be ignored, with another one will
+// be replaced.
+func schedule(ctx context.Context, t *task.Task) {
+	scheduledMu.Lock()
+	if e, ok := scheduled[t.ID]; ok {
+		if e.expiredAt == t.ExpiredAt {
+			scheduledMu.Unlock()
+			return
+		}
+		e.cancel()
+	}
+	waitCtx, cancel := context.WithCancel(ctx)
+	scheduled[t.ID] = entry{expiredAt: t.ExpiredAt, cancel: cancel}
+	scheduledMu.Unlock()
+
+	delay := time.Duration(int64(t.ExpiredAt) - time.Now().UnixNano())
+	go func(id, expiredAt uint64, expired <-chan struct{}) {
+		select {
+		case <-waitCtx.Done():
+		case <-expired:
+			fire(ctx, id, expiredAt)
+		}
+	}(t.ID, t.ExpiredAt, tw.After(delay))
+}
+
+// member the roles of a node in the cluster, which decide whether the node
+// dispatches an expired task.
+type member struct {
+	elector  *cluster.Elector
+	registry *cluster.Registry
+}
+
+// fire reloads the task when it expired, and dispatches it by current node
+// if it still is enabled and has not been rescheduled meanwhile.
+func fire(ctx context.Context, id, expiredAt uint64) {
+	scheduledMu.Lock()
+	if e, ok := scheduled[id]; ok && e.expiredAt == expiredAt {
+		e.cancel()
+		delete(scheduled, id)
+	}
+	scheduledMu.Unlock()
 
-func Start() {
+	fireAs(ctx, member{elector: elector, registry: registry}, id, expiredAt)
+}
+
+// fireAs dispatches the expired task by the node of m, the SINGLETON task is
+// dispatched by the leader only, the MULTIPLE task by every live node. The
+// wheels of nodes are not in sync, so the late nodes dispatch their shards of
+// the MULTIPLE task even if a peer has fired the slot and moved expired_at.
+func fireAs(ctx context.Context, m member, id, expiredAt uint64) {
+	t, err := task.Get(id)
+	if err != nil || t == nil {
+		schedulerLog.WithField("id", id).Warnf("skipped task which can not be loaded: %v", err)
+		return
+	}
+	firedByPeer := t.SchedulingCategory == task.MULTIPLE && expiredAt > 0 && t.FiredAt == expiredAt
+	if !firedByPeer && (t.DeletedAt > 0 || t.Status != task.ENABLED || t.ExpiredAt != expiredAt) {
+		schedulerLog.WithField("id", id).Debug("skipped task which has been changed")
+		return
+	}
+	if t.SchedulingCategory == task.MULTIPLE {
+		index, total, ok := m.registry.Shard()
+		if !ok {
+			schedulerLog.WithField("id", id).Warn("skipped MULTIPLE task on the node which is not alive")
+			return
+		}
+		ctx = executor.WithShard(ctx, executor.Shard{Index: index, Total: total})
+	} else if !m.elector.IsLeader() {
+		// the leader reschedules it, others will load the next one by refilling.
+		schedulerLog.WithField("id", id).Debug("skipped SINGLETON task on follower")
+		return
+	}
+	// the slot has been counted and rescheduled by the peer.
+	if firedByPeer || reschedule(ctx, t) {
+		dispatch(ctx, t, schedulingrecord.SCHEDULED)
+	}
+}
+
+// reschedule counts the run and computes the next expired_at of the DelayJob
+// by its cron, and places it onto the time wheel if it falls into the
+// horizon. The expired_at of one-shot task is cleared, so that it will not be
+// caught up as missed, returns false if it has been fired meanwhile, e.g. by
+// the former leader or the catch-up of missed runs. The recurring task is disabled once it's exhausted by
+// its end_at or max_runs, returns false if it was exhausted before this run,
+// e.g. the limits have been changed, or this run is excluded by its calendar,
+// e.g. the calendar has been changed since the run was scheduled, or its
+// cron can't be evaluated.
+func reschedule(ctx context.Context, t *task.Task) bool {
+	if !recurring(t) {
+		ok, err := task.Fire(t.ID, t.ExpiredAt, 0, 1)
+		if err != nil {
+			schedulerLog.WithField("id", t.ID).Errorf("occurred exception when clearing expired_at: %v", err)
+			return false
+		}
+		// fired by other nodes meanwhile.
+		return ok
+	}
+	if reason := exhausted(t, t.Runs, time.Unix(0, int64(t.ExpiredAt))); reason != "" {
+		disable(t, reason)
+		return false
+	}
+	c, err := cronOf(t)
+	if err != nil {
+		// not fired without claiming the slot, or every node would run it.
+		schedulerLog.WithField("id", t.ID).Warnf("skipped firing task with invalid cron, timezone or calendar: %v", err)
+		return false
+	}
+	// the excluded run is neither executed nor counted.
+	excluded, why := c.excluded(time.Unix(0, int64(t.ExpiredAt)))
+	runs := 1
+	if excluded {
+		runs = 0
+	}
+	// computes from expired_at so that all nodes get the same one, unless
+	// it has fallen behind. The slots excluded by the calendar are skipped.
+	next := c.Next(time.Unix(0, int64(t.ExpiredAt)))
+	if now := time.Now(); !next.IsZero() && next.Before(now) {
+		next = c.Next(now)
+	}
+	reason := exhausted(t, t.Runs+runs, next)
+	var nextAt uint64
+	if reason == "" {
+		nextAt = uint64(next.UnixNano())
+	}
+
+	ok, err := task.Fire(t.ID, t.ExpiredAt, nextAt, runs)
+	if err != nil {
+		schedulerLog.WithField("id", t.ID).Errorf("occurred exception when rescheduling task: %v", err)
+		return !excluded
+	}
+	if !ok {
+		// rescheduled by other nodes meanwhile, follows the latest one.
+		latest, err := task.GetExcludeDeleted(t.ID)
+		if err == nil && latest.Status == task.ENABLED && latest.ExpiredAt > t.ExpiredAt {
+			scheduleWithinHorizon(ctx, latest)
+		}
+		return !excluded
+	}
+	if excluded {
+		skip(t, schedulingrecord.SCHEDULED, why)
+	}
+	if reason != "" {
+		// this is the last run.
+		disable(t, reason)
+		return !excluded
+	}
+	rescheduled := *t
+	rescheduled.ExpiredAt = nextAt
+	scheduleWithinHorizon(ctx, &rescheduled)
+	return !excluded
+}
+
+// recurring returns true if the task is rescheduled by its cron after fired.
+func recurring(t *task.Task) bool {
+	return t.Type == task.DelayJob && t.Cron != ""
+}
 
+// scheduleWithinHorizon places the task onto the time wheel if it falls into
+// the horizon, otherwise leaves it to refilling.
+func scheduleWithinHorizon(ctx context.Context, t *task.Task) {
+	if int64(t.ExpiredAt) < time.Now().Add(horizon).UnixNano() {
+		schedule(ctx, t)
+	}
 }
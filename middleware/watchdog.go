@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/galaxy-center/galaxy/config"
+	"github.com/galaxy-center/galaxy/executor"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+)
+
+const (
+	// watchdogInterval how often the overdue records are checked.
+	watchdogInterval = 30 * time.Second
+	// watchdogGrace gives the executing node a chance to record the result
+	// by itself after the deadline.
+	watchdogGrace = 30 * time.Second
+	// watchdogBatch max records are handled in one round.
+	watchdogBatch = 100
+	// defaultDelayRetention is used while config.DelayConfig#RetentionDays
+	// is absent.
+	defaultDelayRetention = 7 * 24 * time.Hour
+)
+
+// startWatchdog checks the RUNNING records whose deadline has passed and the
+// RUNNABLE records whose retry is overdue periodically on the leader, e.g.
+// the executing node crashed. The delivered delay tasks are purged after
+// the retention as well.
+func startWatchdog(ctx context.Context) {
+	go func(ctx context.Context) {
+		ticker := time.NewTicker(watchdogInterval)
+
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				schedulerLog.Debug("Stopping the watchdog")
+				return
+
+			case <-ticker.C:
+				if elector.IsLeader() {
+					watch(ctx)
+					resumeRetries(ctx)
+					purge()
+				}
+			}
+		}
+	}(ctx)
+}
+
+// watch requeues the overdue records if their retry policy allows, otherwise
+// marks them TIMEOUT.
+func watch(ctx context.Context) {
+	records, err := schedulingrecord.GetOverdueRunning(uint64(time.Now().Add(-watchdogGrace).UnixNano()), watchdogBatch)
+	if err != nil {
+		schedulerLog.Errorf("occurred exception when loading overdue records: %v", err)
+		return
+	}
+	for i := range records {
+		record := &records[i]
+		res := executor.Result{
+			Status:  schedulingrecord.TIMEOUT,
+			Message: fmt.Sprintf("deadline exceeded %s without result, the executing node may be crashed", watchdogGrace),
+		}
+
+		t, err := task.GetExcludeDeleted(record.TaskID)
+		if err == nil && runnable(t, record) && retry(ctx, t, record, res) {
+			schedulerLog.WithField("record", record.ID).Warn("requeued overdue record")
+			continue
+		}
+		if updateStatus(record.ID, schedulingrecord.RUNNING, schedulingrecord.TIMEOUT, withMessage(res.Message)) == nil {
+			schedulerLog.WithField("record", record.ID).Warn("marked overdue record as timeout")
+			if t != nil {
+				cleanup(t, record)
+				advance(ctx, t, record, schedulingrecord.TIMEOUT)
+			}
+		}
+	}
+}
+
+// resumeRetries executes the pending retries which are overdue on the leader,
+// if the nodes which held them are not alive, e.g. they crashed or stopped.
+// The claim hands the record over to the leader, so that a record is resumed
+// once, and the transition to RUNNING lets only one of the nodes execute it.
+func resumeRetries(ctx context.Context) {
+	alive := registry.Nodes()
+	if len(alive) == 0 {
+		// current node is not alive either, can't tell whose retries are lost.
+		return
+	}
+	now := time.Now()
+	records, err := schedulingrecord.GetDueRetries(uint64(now.Add(-watchdogGrace).UnixNano()), alive, watchdogBatch)
+	if err != nil {
+		schedulerLog.Errorf("occurred exception when loading overdue retries: %v", err)
+		return
+	}
+	for i := range records {
+		record := &records[i]
+		// includes the deleted one, so that its workflow run can be advanced.
+		t, err := task.Get(record.TaskID)
+		if err != nil {
+			schedulerLog.WithField("record", record.ID).Errorf("occurred exception when loading task of overdue retry: %v", err)
+			continue
+		}
+		if t == nil {
+			// removed permanently, the retry is FAILED by resume.
+			t = &task.Task{ID: record.TaskID}
+		}
+		ok, err := schedulingrecord.ClaimRetry(record.ID, record.NextAttemptAt, uint64(now.UnixNano()), record.NodeID, config.GetNodeID())
+		if err != nil {
+			schedulerLog.WithField("record", record.ID).Errorf("occurred exception when claiming overdue retry: %v", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		schedulerLog.WithField("record", record.ID).Warnf("Resumed overdue retry attempt %d", record.Attempt)
+		go resume(ctx, t, record)
+	}
+}
+
+// purge removes the tasks of delay messages which have been deleted softly
+// for longer than the retention permanently, e.g. the delivered ones.
+func purge() {
+	retention := defaultDelayRetention
+	if days := config.Global().Delay.RetentionDays; days > 0 {
+		retention = time.Duration(days) * 24 * time.Hour
+	}
+	n, err := task.PurgeDelays(uint64(time.Now().Add(-retention).UnixNano()), watchdogBatch)
+	if err != nil {
+		schedulerLog.Errorf("occurred exception when purging delay tasks: %v", err)
+		return
+	}
+	if n > 0 {
+		schedulerLog.Infof("Purged %d delay tasks", n)
+	}
+}
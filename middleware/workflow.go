@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+	"github.com/galaxy-center/galaxy/models/workflow"
+)
+
+// beginRun creates a run of the workflow which the root task belongs to, the
+// record of root joins the run.
+func beginRun(t *task.Task, record *schedulingrecord.SchedulingRecord) error {
+	run := &workflow.Run{
+		WorkflowID:  *t.WorkflowID,
+		TriggerType: record.TriggerType,
+		CreatedBy:   record.CreatedBy,
+	}
+	if err := workflow.CreateRun(run); err != nil {
+		return err
+	}
+	record.WorkflowRunID = &run.ID
+	schedulerLog.WithField("workflow", run.WorkflowID).WithField("run", run.ID).Infof("Workflow run began by task %d", t.ID)
+	return nil
+}
+
+// startRoots starts the other roots of the workflow in the run which is
+// began by the root task.
+func startRoots(ctx context.Context, t *task.Task, record *schedulingrecord.SchedulingRecord) {
+	nodes, err := task.GetByWorkflow(*t.WorkflowID)
+	if err != nil {
+		schedulerLog.WithField("run", *record.WorkflowRunID).Errorf("occurred exception when loading workflow: %v", err)
+		return
+	}
+	for i := range nodes {
+		if n := &nodes[i]; n.ID != t.ID && n.IsRoot() {
+			startNode(ctx, n, record)
+		}
+	}
+}
+
+// advance starts the downstream nodes of task in the workflow run once all
+// of their upstreams are FINISHED, or skips them if the task is not.
+func advance(ctx context.Context, t *task.Task, record *schedulingrecord.SchedulingRecord, status schedulingrecord.Status) {
+	if record.WorkflowRunID == nil || t.WorkflowID == nil {
+		return
+	}
+	nodes, err := task.GetByWorkflow(*t.WorkflowID)
+	if err != nil {
+		schedulerLog.WithField("run", *record.WorkflowRunID).Errorf("occurred exception when loading workflow: %v", err)
+		return
+	}
+	var statuses map[uint64]schedulingrecord.Status
+	for i := range nodes {
+		n := &nodes[i]
+		if !n.Upstreams.Contains(t.ID) {
+			continue
+		}
+		if status != schedulingrecord.FINISHED {
+			skipNode(ctx, n, downstreamOf(record, n), fmt.Sprintf("workflow: upstream %d is %s", t.ID, status))
+			continue
+		}
+		if statuses == nil {
+			if statuses, err = runStatuses(*record.WorkflowRunID); err != nil {
+				schedulerLog.WithField("run", *record.WorkflowRunID).Errorf("occurred exception when loading records: %v", err)
+				return
+			}
+		}
+		if upstreamsFinished(n, statuses) {
+			startNode(ctx, n, record)
+		}
+	}
+}
+
+// startNode starts the task in the workflow run of upstream record, it's
+// skipped if the task is not runnable. Only one of the concurrent upstreams
+// starts it, others are ignored by the unique key of run and task.
+func startNode(ctx context.Context, n *task.Task, upstream *schedulingrecord.SchedulingRecord) {
+	record := downstreamOf(upstream, n)
+	if !runnable(n, record) {
+		skipNode(ctx, n, record, fmt.Sprintf("workflow: task is %s", n.Status))
+		return
+	}
+	err := start(ctx, n, record)
+	if task.IsDuplicated(err) {
+		schedulerLog.WithField("task", n.ID).WithField("run", *record.WorkflowRunID).Debug("node has been started by others")
+	} else if err != nil {
+		schedulerLog.WithField("task", n.ID).Errorf("occurred exception when starting node: %v", err)
+	}
+}
+
+// skipNode records the task is skipped in the workflow run, and skips its
+// downstreams as well.
+func skipNode(ctx context.Context, n *task.Task, record *schedulingrecord.SchedulingRecord, reason string) {
+	err := skipRecord(n, record, reason)
+	if err == nil {
+		advance(ctx, n, record, schedulingrecord.SKIPPED)
+	} else if !task.IsDuplicated(err) {
+		schedulerLog.WithField("task", n.ID).Errorf("occurred exception when inserting skipped record: %v", err)
+	}
+}
+
+// downstreamOf returns the new record of task in the same run as upstream.
+func downstreamOf(upstream *schedulingrecord.SchedulingRecord, n *task.Task) *schedulingrecord.SchedulingRecord {
+	return &schedulingrecord.SchedulingRecord{
+		TaskID:        n.ID,
+		WorkflowRunID: upstream.WorkflowRunID,
+		TriggerType:   upstream.TriggerType,
+		CreatedBy:     schedulerName,
+	}
+}
+
+// runStatuses returns task id -> the status of its latest record in the run.
+func runStatuses(runID uint64) (map[uint64]schedulingrecord.Status, error) {
+	records, err := schedulingrecord.GetByWorkflowRun(runID)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[uint64]schedulingrecord.Status, len(records))
+	for _, r := range records {
+		statuses[r.TaskID] = r.Status
+	}
+	return statuses, nil
+}
+
+// upstreamsFinished returns true if all of the upstreams of task are FINISHED.
+func upstreamsFinished(n *task.Task, statuses map[uint64]schedulingrecord.Status) bool {
+	for _, up := range n.Upstreams {
+		if statuses[up] != schedulingrecord.FINISHED {
+			return false
+		}
+	}
+	return true
+}
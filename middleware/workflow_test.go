@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"testing"
+
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpstreamsFinished(t *testing.T) {
+	notify := &task.Task{ID: 3, Upstreams: task.IDs{1, 2}}
+	statuses := map[uint64]schedulingrecord.Status{1: schedulingrecord.FINISHED}
+	assert.False(t, upstreamsFinished(notify, statuses), "upstream 2 has not been started")
+
+	statuses[2] = schedulingrecord.RUNNING
+	assert.False(t, upstreamsFinished(notify, statuses))
+
+	statuses[2] = schedulingrecord.FINISHED
+	assert.True(t, upstreamsFinished(notify, statuses))
+
+	statuses[2] = schedulingrecord.SKIPPED
+	assert.False(t, upstreamsFinished(notify, statuses))
+}
+
+func TestDownstreamOf(t *testing.T) {
+	runID := uint64(7)
+	upstream := &schedulingrecord.SchedulingRecord{
+		ID:            1,
+		TaskID:        1,
+		WorkflowRunID: &runID,
+		TriggerType:   schedulingrecord.MANUAL,
+		Status:        schedulingrecord.FINISHED,
+		CreatedBy:     "tester",
+	}
+	record := downstreamOf(upstream, &task.Task{ID: 2})
+	assert.EqualValues(t, 2, record.TaskID)
+	assert.Equal(t, &runID, record.WorkflowRunID)
+	assert.Equal(t, schedulingrecord.TriggerType(schedulingrecord.MANUAL), record.TriggerType)
+	assert.Equal(t, schedulerName, record.CreatedBy)
+	assert.Zero(t, record.ID)
+}
+
+func TestIsRoot(t *testing.T) {
+	workflowID := uint64(1)
+	assert.False(t, (&task.Task{}).IsRoot(), "not in workflow")
+	assert.True(t, (&task.Task{WorkflowID: &workflowID}).IsRoot())
+	assert.False(t, (&task.Task{WorkflowID: &workflowID, Upstreams: task.IDs{2}}).IsRoot())
+}
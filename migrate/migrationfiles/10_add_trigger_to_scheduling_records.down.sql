@@ -0,0 +1,4 @@
+-- Drop the trigger from 'scheduling_records'
+alter table scheduling_records
+drop column trigger_type,
+drop column overrides;
@@ -0,0 +1,4 @@
+alter table scheduling_records
+add column trigger_type varchar
+(32) not null default 'SCHEDULED' comment 'how the run is triggered, e.g. SCHEDULED, MANUAL' after task_id,
+add column overrides JSON default null comment 'merged into the task config content for this run only' after deadline_at;
@@ -0,0 +1,3 @@
+-- Drop the misfire policy from 'tasks'
+alter table tasks
+drop column misfire_policy;
@@ -0,0 +1,3 @@
+alter table tasks
+add column misfire_policy varchar
+(32) not null default 'FIRE_ONCE' comment 'what to do with the missed runs, e.g. FIRE_ONCE, FIRE_ALL, SKIP' after retry_status_codes;
@@ -0,0 +1,3 @@
+-- Drop the timezone from 'tasks'
+alter table tasks
+drop column timezone;
@@ -0,0 +1,3 @@
+alter table tasks
+add column timezone varchar
+(64) default null comment 'the IANA timezone which the cron is evaluated in, e.g. Asia/Shanghai' after cron;
@@ -0,0 +1,2 @@
+-- Drop the table 'workflows'
+DROP TABLE IF EXISTS workflows;
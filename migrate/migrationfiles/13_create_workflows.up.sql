@@ -0,0 +1,14 @@
+create table
+if not exists workflows
+(
+id bigint unsigned auto_increment not null comment 'primary key' primary key,
+name varchar
+(32) not null comment 'workflow name',
+deleted_at bigint unsigned not null default '0' comment 'deleted time',
+created_at bigint unsigned not null comment 'created time',
+created_by varchar
+(32) default null comment 'created by',
+updated_at bigint unsigned not null comment 'last updated time',
+updated_by varchar
+(32) default null comment 'last updated by'
+) comment 'workflows, the DAG of tasks' charset = utf8mb4;
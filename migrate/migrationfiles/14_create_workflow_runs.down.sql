@@ -0,0 +1,2 @@
+-- Drop the table 'workflow_runs'
+DROP TABLE IF EXISTS workflow_runs;
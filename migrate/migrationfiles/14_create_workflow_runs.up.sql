@@ -0,0 +1,13 @@
+create table
+if not exists workflow_runs
+(
+id bigint unsigned auto_increment not null comment 'primary key' primary key,
+workflow_id bigint unsigned not null comment 'relation of workflow',
+trigger_type varchar
+(32) not null default 'SCHEDULED' comment 'how the run is triggered, e.g. SCHEDULED, MANUAL',
+created_at bigint unsigned not null comment 'created time',
+created_by varchar
+(32) default null comment 'created by',
+index idx_workflow_runs_workflow_id
+(workflow_id)
+) comment 'workflow runs, every node is executed at most once in a run' charset = utf8mb4;
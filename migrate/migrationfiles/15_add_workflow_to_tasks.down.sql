@@ -0,0 +1,5 @@
+-- Drop the workflow from 'tasks'
+alter table tasks
+drop index idx_tasks_workflow_id,
+drop column upstreams,
+drop column workflow_id;
@@ -0,0 +1,5 @@
+alter table tasks
+add column workflow_id bigint unsigned default null comment 'relation of workflow' after task_config_id,
+add column upstreams JSON default null comment 'ids of the upstream tasks in the workflow' after workflow_id,
+add index idx_tasks_workflow_id
+(workflow_id);
@@ -0,0 +1,4 @@
+-- Drop the workflow run from 'scheduling_records'
+alter table scheduling_records
+drop index uk_scheduling_records_workflow_run_id_task_id,
+drop column workflow_run_id;
@@ -0,0 +1,4 @@
+alter table scheduling_records
+add column workflow_run_id bigint unsigned default null comment 'relation of workflow run' after task_id,
+add unique index uk_scheduling_records_workflow_run_id_task_id
+(workflow_run_id, task_id);
@@ -0,0 +1,4 @@
+-- Drop the concurrency from 'tasks'
+alter table tasks
+drop column max_concurrency,
+drop column overlap_policy;
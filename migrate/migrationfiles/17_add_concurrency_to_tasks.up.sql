@@ -0,0 +1,4 @@
+alter table tasks
+add column max_concurrency int not null default '0' comment 'max runs executing at once on a node, 0 means unlimited' after misfire_policy,
+add column overlap_policy varchar
+(32) not null default 'SKIP' comment 'what to do with the new run beyond max concurrency, e.g. SKIP, QUEUE, REPLACE' after max_concurrency;
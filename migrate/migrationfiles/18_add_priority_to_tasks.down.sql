@@ -0,0 +1,3 @@
+-- Drop the priority from 'tasks'
+alter table tasks
+drop column priority;
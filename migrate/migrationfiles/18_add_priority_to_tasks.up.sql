@@ -0,0 +1,2 @@
+alter table tasks
+add column priority int not null default '0' comment 'from 0 to 9, the runs of higher priority take the workers first' after overlap_policy;
@@ -0,0 +1,7 @@
+-- Drop the run limits from 'tasks'
+alter table tasks
+drop column start_at,
+drop column end_at,
+drop column max_runs,
+drop column runs,
+drop column disabled_reason;
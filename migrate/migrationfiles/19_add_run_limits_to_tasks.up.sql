@@ -0,0 +1,7 @@
+alter table tasks
+add column start_at bigint unsigned not null default '0' comment 'the cron fires from the time, 0 means now' after priority,
+add column end_at bigint unsigned not null default '0' comment 'the cron fires until the time, 0 means forever' after start_at,
+add column max_runs int not null default '0' comment 'the max number of scheduled runs, 0 means unlimited' after end_at,
+add column runs int not null default '0' comment 'the number of scheduled runs' after max_runs,
+add column disabled_reason varchar
+(255) default null comment 'why the task is disabled by scheduler, e.g. max_runs reached' after runs;
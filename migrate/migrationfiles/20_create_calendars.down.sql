@@ -0,0 +1,2 @@
+-- Drop the table 'calendars'
+DROP TABLE IF EXISTS calendars;
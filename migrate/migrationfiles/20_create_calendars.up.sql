@@ -0,0 +1,17 @@
+create table
+if not exists calendars
+(
+id bigint unsigned auto_increment not null comment 'primary key' primary key,
+name varchar
+(32) not null comment 'calendar name',
+timezone varchar
+(64) default null comment 'the IANA timezone of excluded dates, e.g. Asia/Shanghai',
+exclusions JSON default null comment 'excluded dates and time ranges, e.g. holidays, maintenance windows',
+deleted_at bigint unsigned not null default '0' comment 'deleted time',
+created_at bigint unsigned not null comment 'created time',
+created_by varchar
+(32) default null comment 'created by',
+updated_at bigint unsigned not null comment 'last updated time',
+updated_by varchar
+(32) default null comment 'last updated by'
+) comment 'business calendars, tasks do not fire in their excluded slots' charset = utf8mb4;
@@ -0,0 +1,5 @@
+-- Drop the calendar from 'tasks'
+alter table tasks
+drop foreign key fk_tasks_calendar_id,
+drop key idx_tasks_calendar_id,
+drop column calendar_id;
@@ -0,0 +1,6 @@
+alter table tasks
+add column calendar_id bigint unsigned default null comment 'relation of calendar, the excluded slots are skipped' after timezone,
+add key idx_tasks_calendar_id (calendar_id),
+add constraint fk_tasks_calendar_id foreign key
+(calendar_id) references calendars
+(id);
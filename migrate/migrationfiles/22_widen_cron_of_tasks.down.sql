@@ -0,0 +1,4 @@
+-- Narrow the cron of 'tasks'
+alter table tasks
+modify column cron varchar
+(32) default null comment 'cron expressions, refer https://cron.qqe2.com';
@@ -0,0 +1,3 @@
+alter table tasks
+modify column cron varchar
+(255) default null comment 'cron expressions, refer https://cron.qqe2.com';
@@ -0,0 +1,3 @@
+-- Drop the next attempt from 'scheduling_records'
+alter table scheduling_records
+drop column next_attempt_at;
@@ -0,0 +1,2 @@
+alter table scheduling_records
+add column next_attempt_at bigint unsigned not null default '0' comment 'the time of the pending retry while RUNNABLE, 0 means none' after deadline_at;
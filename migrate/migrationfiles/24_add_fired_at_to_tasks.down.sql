@@ -0,0 +1,3 @@
+-- Drop the fired_at from 'tasks'
+alter table tasks
+drop column fired_at;
@@ -0,0 +1,2 @@
+alter table tasks
+add column fired_at bigint unsigned not null default '0' comment 'the expired_at which is fired last, the late nodes dispatch their shards of it' after expired_at;
@@ -0,0 +1,3 @@
+-- Drop the node id from 'scheduling_records'
+alter table scheduling_records
+drop column node_id;
@@ -0,0 +1,2 @@
+alter table scheduling_records
+add column node_id varchar(64) not null default '' comment 'the node which holds the pending retry while RUNNABLE' after next_attempt_at;
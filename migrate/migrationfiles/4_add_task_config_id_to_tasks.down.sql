@@ -0,0 +1,4 @@
+-- Drop the relation of task config from 'tasks'
+alter table tasks
+drop foreign key fk_tasks_task_config_id,
+drop column task_config_id;
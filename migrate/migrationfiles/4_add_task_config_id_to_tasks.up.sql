@@ -0,0 +1,5 @@
+alter table tasks
+add column task_config_id bigint unsigned default null comment 'relation of task config' after executor,
+add constraint fk_tasks_task_config_id foreign key
+(task_config_id) references task_configs
+(id);
@@ -0,0 +1,8 @@
+-- Drop the retry policy from 'tasks'
+alter table tasks
+drop column retry_max_attempts,
+drop column retry_backoff,
+drop column retry_delay,
+drop column retry_max_delay,
+drop column retry_jitter,
+drop column retry_status_codes;
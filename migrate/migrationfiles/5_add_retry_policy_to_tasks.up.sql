@@ -0,0 +1,9 @@
+alter table tasks
+add column retry_max_attempts int not null default '0' comment 'max attempts of a run, includes the first one, 0 means never retry' after executor,
+add column retry_backoff varchar
+(32) default null comment 'retry backoff, e.g. FIXED, EXPONENTIAL' after retry_max_attempts,
+add column retry_delay int not null default '0' comment 'retry base delay, unit is seconds' after retry_backoff,
+add column retry_max_delay int not null default '0' comment 'retry max delay, unit is seconds, 0 means unlimited' after retry_delay,
+add column retry_jitter double not null default '0' comment 'retry delay jitter ratio, from 0 to 1' after retry_max_delay,
+add column retry_status_codes varchar
+(128) default null comment 'retryable status codes separated by comma, empty means any' after retry_jitter;
@@ -0,0 +1,3 @@
+-- Drop the attempt from 'scheduling_records'
+alter table scheduling_records
+drop column attempt;
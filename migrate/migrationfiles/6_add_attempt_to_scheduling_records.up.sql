@@ -0,0 +1,2 @@
+alter table scheduling_records
+add column attempt int not null default '1' comment 'attempt of the run, starts from 1' after status;
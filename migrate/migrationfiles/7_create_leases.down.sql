@@ -0,0 +1,2 @@
+-- Drop the table 'leases'
+DROP TABLE IF EXISTS leases;
@@ -0,0 +1,11 @@
+create table
+if not exists leases
+(
+name varchar
+(64) not null comment 'lease name, primary key' primary key,
+holder varchar
+(64) not null comment 'node id of the lease holder',
+expired_at bigint unsigned not null comment 'lease expired time',
+created_at bigint unsigned not null comment 'created time',
+updated_at bigint unsigned not null comment 'last updated time'
+) comment 'distributed leases between nodes' charset = utf8mb4;
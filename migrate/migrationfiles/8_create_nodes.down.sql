@@ -0,0 +1,2 @@
+-- Drop the table 'nodes'
+DROP TABLE IF EXISTS nodes;
@@ -0,0 +1,9 @@
+create table
+if not exists nodes
+(
+id varchar
+(64) not null comment 'node id, primary key' primary key,
+heartbeat_at bigint unsigned not null comment 'last heartbeat time',
+created_at bigint unsigned not null comment 'created time',
+updated_at bigint unsigned not null comment 'last updated time'
+) comment 'registry of live nodes' charset = utf8mb4;
@@ -0,0 +1,3 @@
+-- Drop the deadline from 'scheduling_records'
+alter table scheduling_records
+drop column deadline_at;
@@ -0,0 +1,2 @@
+alter table scheduling_records
+add column deadline_at bigint unsigned not null default '0' comment 'deadline of the running attempt, 0 means unlimited' after attempt;
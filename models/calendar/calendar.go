@@ -0,0 +1,202 @@
+package calendar
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	galaxyDB "github.com/galaxy-center/galaxy/lifecycle"
+	"gorm.io/gorm"
+)
+
+// DateLayout the layout of excluded dates.
+const DateLayout = "2006-01-02"
+
+// Date a whole day which is excluded in the timezone of calendar, e.g. a
+// public holiday.
+type Date struct {
+	Date    string `json:"date" toml:"date" yaml:"date"`
+	Summary string `json:"summary,omitempty" toml:"summary" yaml:"summary,omitempty"`
+}
+
+// Range a time range [start, end) in unix nanoseconds which is excluded, e.g.
+// a maintenance window.
+type Range struct {
+	Start   uint64 `json:"start" toml:"start" yaml:"start"`
+	End     uint64 `json:"end" toml:"end" yaml:"end"`
+	Summary string `json:"summary,omitempty" toml:"summary" yaml:"summary,omitempty"`
+}
+
+// Exclusions the excluded dates and time ranges of calendar, which are
+// stored as a JSON object.
+type Exclusions struct {
+	Dates  []Date  `json:"dates" toml:"dates" yaml:"dates"`
+	Ranges []Range `json:"ranges" toml:"ranges" yaml:"ranges"`
+}
+
+// Value implements driver.Valuer.
+func (e Exclusions) Value() (driver.Value, error) {
+	b, err := json.Marshal(e)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner.
+func (e *Exclusions) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*e = Exclusions{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unsupported exclusions %T", value)
+	}
+	return json.Unmarshal(b, e)
+}
+
+// Calendar is an object representing the database table, the tasks which
+// reference it do not fire in its excluded slots.
+type Calendar struct {
+	ID         uint64     `gorm:"primaryKey,autoIncrement" json:"id" toml:"id" yaml:"id"`
+	Name       string     `gorm:"column:name" json:"name" toml:"name" yaml:"name"`
+	Timezone   string     `gorm:"column:timezone" json:"timezone,omitempty" toml:"timezone" yaml:"timezone,omitempty"`
+	Exclusions Exclusions `gorm:"type:json;column:exclusions" json:"exclusions" toml:"exclusions" yaml:"exclusions"`
+	DeletedAt  uint64     `gorm:"column:deleted_at" json:"deleted_at" toml:"deleted_at" yaml:"deleted_at"`
+	CreatedAt  uint64     `gorm:"autoCreateTime:nano" json:"created_at" toml:"created_at" yaml:"created_at"`
+	CreatedBy  string     `gorm:"column:created_by" json:"created_by,omitempty" toml:"created_by" yaml:"created_by,omitempty"`
+	UpdatedAt  uint64     `gorm:"autoUpdateTime:nano" json:"updated_at" toml:"updated_at" yaml:"updated_at"`
+	UpdatedBy  string     `gorm:"column:updated_by" json:"updated_by,omitempty" toml:"updated_by" yaml:"updated_by,omitempty"`
+}
+
+// CalendarColumns table field name.
+var CalendarColumns = struct {
+	ID         string
+	Name       string
+	Timezone   string
+	Exclusions string
+	DeletedAt  string
+	CreatedAt  string
+	CreatedBy  string
+	UpdatedAt  string
+	UpdatedBy  string
+}{
+	ID:         "id",
+	Name:       "name",
+	Timezone:   "timezone",
+	Exclusions: "exclusions",
+	DeletedAt:  "deleted_at",
+	CreatedAt:  "created_at",
+	CreatedBy:  "created_by",
+	UpdatedAt:  "updated_at",
+	UpdatedBy:  "updated_by",
+}
+
+// Tabler defines the table name.
+type Tabler interface {
+	TableName() string
+}
+
+// TableName 会将 Calendar 的表名重写为 `calendars`
+func (Calendar) TableName() string {
+	return "calendars"
+}
+
+// Location returns the timezone which the excluded dates are in, empty
+// timezone is the local one of node.
+func (c *Calendar) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(c.Timezone)
+}
+
+// Excluded returns true with the reason if t falls into an excluded date or
+// time range of the calendar.
+func (c *Calendar) Excluded(t time.Time) (bool, string) {
+	loc, err := c.Location()
+	if err != nil {
+		loc = time.Local
+	}
+	day := t.In(loc).Format(DateLayout)
+	for _, d := range c.Exclusions.Dates {
+		if d.Date == day {
+			return true, c.reason(d.Summary, day)
+		}
+	}
+	at := uint64(t.UnixNano())
+	for _, r := range c.Exclusions.Ranges {
+		if r.Start <= at && at < r.End {
+			return true, c.reason(r.Summary, fmt.Sprintf("%s ~ %s",
+				time.Unix(0, int64(r.Start)).In(loc).Format(time.RFC3339),
+				time.Unix(0, int64(r.End)).In(loc).Format(time.RFC3339)))
+		}
+	}
+	return false, ""
+}
+
+// reason describes the exclusion by its summary, or what is excluded if the
+// summary is absent.
+func (c *Calendar) reason(summary, excluded string) string {
+	if summary == "" {
+		summary = excluded
+	}
+	return fmt.Sprintf("calendar %q: %s", c.Name, summary)
+}
+
+// Create a single Calendar to db.
+func Create(c *Calendar) error {
+	db := galaxyDB.GetDB()
+	err := db.Create(c).Error
+	return err
+}
+
+// BeforeUpdate do somethings, e.g. updating the updated_at value.
+func (c *Calendar) BeforeUpdate(tx *gorm.DB) (err error) {
+	c.UpdatedAt = uint64(time.Now().UnixNano())
+	return
+}
+
+// UpdatesFromMap updates from specific calendar that will not updating the
+// zero value fields to db.
+// 只能保存map包含字段
+func UpdatesFromMap(id uint64, values map[string]interface{}) error {
+	db := galaxyDB.GetDB()
+	err := db.Model(&Calendar{}).Where("id = ?", id).Updates(values).Error
+	return err
+}
+
+// DeleteAt delete softly. 软删除
+func DeleteAt(id uint64) error {
+	db := galaxyDB.GetDB()
+	err := db.Model(&Calendar{}).Where("id = ?", id).Update("deleted_at", time.Now().UnixNano()).Error
+	return err
+}
+
+// Get returns the calendar by specific id.
+func Get(id uint64) (*Calendar, error) {
+	db := galaxyDB.GetDB()
+	var c Calendar
+	if err := db.First(&c, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetExcludeDeleted returns the calendar that excludes inactived by specific
+// id.
+func GetExcludeDeleted(id uint64) (*Calendar, error) {
+	db := galaxyDB.GetDB()
+	var c Calendar
+	if err := db.Where("id = ?", id).Where("deleted_at = ?", 0).First(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,51 @@
+package calendar
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/galaxy-center/galaxy/config"
+	db "github.com/galaxy-center/galaxy/lifecycle"
+	migrateProvider "github.com/galaxy-center/galaxy/migrate"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	config.SetTestMode(true)
+	db.Init()
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	os.Exit(code)
+}
+
+func TestCreateAndGet(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	window := time.Date(2021, 12, 24, 22, 0, 0, 0, time.UTC)
+	c := &Calendar{
+		Name:     "holidays",
+		Timezone: "Asia/Shanghai",
+		Exclusions: Exclusions{
+			Dates:  []Date{{Date: "2021-10-01", Summary: "National Day"}},
+			Ranges: []Range{{Start: uint64(window.UnixNano()), End: uint64(window.Add(2 * time.Hour).UnixNano())}},
+		},
+		CreatedBy: "tester",
+	}
+	assert.Nil(t, Create(c))
+
+	got, err := GetExcludeDeleted(c.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, c.Exclusions, got.Exclusions)
+
+	assert.Nil(t, DeleteAt(c.ID))
+	_, err = GetExcludeDeleted(c.ID)
+	assert.NotNil(t, err)
+	deleted, err := Get(c.ID)
+	assert.Nil(t, err)
+	assert.True(t, deleted.DeletedAt > 0)
+}
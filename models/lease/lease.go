@@ -0,0 +1,102 @@
+package lease
+
+import (
+	"errors"
+	"time"
+
+	galaxyDB "github.com/galaxy-center/galaxy/lifecycle"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Lease is an object representing the database table, a lease is held by
+// one node until it expired.
+type Lease struct {
+	Name      string `gorm:"primaryKey;column:name" json:"name" toml:"name" yaml:"name"`
+	Holder    string `gorm:"column:holder" json:"holder" toml:"holder" yaml:"holder"`
+	ExpiredAt uint64 `gorm:"column:expired_at" json:"expired_at" toml:"expired_at" yaml:"expired_at"`
+	CreatedAt uint64 `gorm:"autoCreateTime:nano" json:"created_at" toml:"created_at" yaml:"created_at"`
+	UpdatedAt uint64 `gorm:"autoUpdateTime:nano" json:"updated_at" toml:"updated_at" yaml:"updated_at"`
+}
+
+// LeaseColumns table field name.
+var LeaseColumns = struct {
+	Name      string
+	Holder    string
+	ExpiredAt string
+	CreatedAt string
+	UpdatedAt string
+}{
+	Name:      "name",
+	Holder:    "holder",
+	ExpiredAt: "expired_at",
+	CreatedAt: "created_at",
+	UpdatedAt: "updated_at",
+}
+
+// Tabler defines the table name.
+type Tabler interface {
+	TableName() string
+}
+
+// TableName 会将 Lease 的表名重写为 `leases`
+func (Lease) TableName() string {
+	return "leases"
+}
+
+// Acquire takes or renews the lease for holder until now+ttl, returns false
+// if the lease is held by another holder and not expired yet.
+func Acquire(name, holder string, ttl time.Duration) (bool, error) {
+	db := galaxyDB.GetDB()
+	now := time.Now()
+	expiredAt := uint64(now.Add(ttl).UnixNano())
+
+	// renews the own lease or takes over the expired one.
+	tx := db.Model(&Lease{}).
+		Where("name = ?", name).
+		Where("holder = ? OR expired_at < ?", holder, now.UnixNano()).
+		Updates(map[string]interface{}{
+			LeaseColumns.Holder:    holder,
+			LeaseColumns.ExpiredAt: expiredAt,
+		})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	if tx.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// creates the lease if absent, ignores while others created it first.
+	tx = db.Clauses(clause.Insert{Modifier: "IGNORE"}).Create(&Lease{
+		Name:      name,
+		Holder:    holder,
+		ExpiredAt: expiredAt,
+	})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// Release gives up the lease if it's held by holder.
+func Release(name, holder string) error {
+	db := galaxyDB.GetDB()
+	err := db.Model(&Lease{}).
+		Where("name = ?", name).
+		Where("holder = ?", holder).
+		Update(LeaseColumns.ExpiredAt, 0).Error
+	return err
+}
+
+// Get returns the lease by specific name.
+func Get(name string) (*Lease, error) {
+	db := galaxyDB.GetDB()
+	var lease Lease
+	if err := db.Where("name = ?", name).First(&lease).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &lease, nil
+}
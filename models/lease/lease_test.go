@@ -0,0 +1,75 @@
+package lease
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/galaxy-center/galaxy/config"
+	db "github.com/galaxy-center/galaxy/lifecycle"
+	migrateProvider "github.com/galaxy-center/galaxy/migrate"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	config.SetTestMode(true)
+	db.Init()
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	os.Exit(code)
+}
+
+func TestAcquire(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	ok, err := Acquire("test", "nodeA", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, ok, "nodeA should acquire the absent lease")
+
+	ok, _ = Acquire("test", "nodeB", time.Minute)
+	assert.False(t, ok, "nodeB should not acquire the lease held by nodeA")
+
+	ok, _ = Acquire("test", "nodeA", time.Minute)
+	assert.True(t, ok, "nodeA should renew its own lease")
+
+	exist, _ := Get("test")
+	assert.NotNil(t, exist, "exist should be not null")
+	assert.EqualValues(t, "nodeA", exist.Holder, "holder error")
+}
+
+func TestAcquireExpired(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	ok, _ := Acquire("test", "nodeA", 100*time.Millisecond)
+	assert.True(t, ok, "nodeA should acquire the absent lease")
+
+	time.Sleep(200 * time.Millisecond)
+
+	ok, _ = Acquire("test", "nodeB", time.Minute)
+	assert.True(t, ok, "nodeB should take over the expired lease")
+
+	exist, _ := Get("test")
+	assert.EqualValues(t, "nodeB", exist.Holder, "holder error")
+}
+
+func TestRelease(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	Acquire("test", "nodeA", time.Minute)
+
+	Release("test", "nodeB")
+	ok, _ := Acquire("test", "nodeB", time.Minute)
+	assert.False(t, ok, "release by others should be ignored")
+
+	Release("test", "nodeA")
+	ok, _ = Acquire("test", "nodeB", time.Minute)
+	assert.True(t, ok, "nodeB should acquire the released lease")
+}
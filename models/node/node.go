@@ -0,0 +1,67 @@
+package node
+
+import (
+	"time"
+
+	galaxyDB "github.com/galaxy-center/galaxy/lifecycle"
+	"gorm.io/gorm/clause"
+)
+
+// Node is an object representing the database table, a live node keeps
+// its heartbeat.
+type Node struct {
+	ID          string `gorm:"primaryKey;column:id" json:"id" toml:"id" yaml:"id"`
+	HeartbeatAt uint64 `gorm:"column:heartbeat_at" json:"heartbeat_at" toml:"heartbeat_at" yaml:"heartbeat_at"`
+	CreatedAt   uint64 `gorm:"autoCreateTime:nano" json:"created_at" toml:"created_at" yaml:"created_at"`
+	UpdatedAt   uint64 `gorm:"autoUpdateTime:nano" json:"updated_at" toml:"updated_at" yaml:"updated_at"`
+}
+
+// NodeColumns table field name.
+var NodeColumns = struct {
+	ID          string
+	HeartbeatAt string
+	CreatedAt   string
+	UpdatedAt   string
+}{
+	ID:          "id",
+	HeartbeatAt: "heartbeat_at",
+	CreatedAt:   "created_at",
+	UpdatedAt:   "updated_at",
+}
+
+// Tabler defines the table name.
+type Tabler interface {
+	TableName() string
+}
+
+// TableName 会将 Node 的表名重写为 `nodes`
+func (Node) TableName() string {
+	return "nodes"
+}
+
+// Heartbeat registers the node or refreshes its heartbeat.
+func Heartbeat(id string) error {
+	db := galaxyDB.GetDB()
+	err := db.Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{NodeColumns.HeartbeatAt, NodeColumns.UpdatedAt}),
+	}).Create(&Node{
+		ID:          id,
+		HeartbeatAt: uint64(time.Now().UnixNano()),
+	}).Error
+	return err
+}
+
+// Delete delete permanently. 永久删除
+func Delete(id string) error {
+	db := galaxyDB.GetDB()
+	err := db.Where("id = ?", id).Delete(&Node{}).Error
+	return err
+}
+
+// GetAlive returns the nodes ordered by id whose heartbeat is after since.
+func GetAlive(since uint64) ([]Node, error) {
+	db := galaxyDB.GetDB()
+	var nodes []Node
+	err := db.Where("heartbeat_at > ?", since).Order("id").Find(&nodes).Error
+	return nodes, err
+}
@@ -0,0 +1,54 @@
+package node
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/galaxy-center/galaxy/config"
+	db "github.com/galaxy-center/galaxy/lifecycle"
+	migrateProvider "github.com/galaxy-center/galaxy/migrate"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	config.SetTestMode(true)
+	db.Init()
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	os.Exit(code)
+}
+
+func TestHeartbeat(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	Heartbeat("nodeB")
+	Heartbeat("nodeA")
+	since := uint64(time.Now().UnixNano())
+	time.Sleep(10 * time.Millisecond)
+	Heartbeat("nodeB")
+
+	nodes, _ := GetAlive(0)
+	assert.Len(t, nodes, 2, "nodes length error")
+	assert.EqualValues(t, "nodeA", nodes[0].ID, "nodes should be ordered by id")
+
+	nodes, _ = GetAlive(since)
+	assert.Len(t, nodes, 1, "only nodeB is alive")
+	assert.EqualValues(t, "nodeB", nodes[0].ID, "id error")
+}
+
+func TestDelete(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	Heartbeat("nodeA")
+	Delete("nodeA")
+
+	nodes, _ := GetAlive(0)
+	assert.Len(t, nodes, 0, "nodeA should be deleted")
+}
@@ -2,14 +2,16 @@ package schedulingrecord
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	galaxyDB "github.com/galaxy-center/galaxy/lifecycle"
 	models "github.com/galaxy-center/galaxy/models"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
-// Status new->runnable->running->finished/failed
+// Status new->runnable->running->finished/failed/timeout
 type Status string
 
 const (
@@ -17,48 +19,133 @@ const (
 	NEW Status = "NEW"
 	// RUNNABLE NEW->RUNNABLE / RUNNING->RUNNABLE.
 	RUNNABLE = "RUNNABLE"
-	// RUNNING RUNNABLE->RUNNING / RUNNING->RUNNABLE/ RUNNING -> FINISHED / RUNNING->FAILED.
+	// RUNNING RUNNABLE->RUNNING / RUNNING->RUNNABLE/ RUNNING -> FINISHED / RUNNING->FAILED / RUNNING->TIMEOUT.
 	RUNNING = "RUNNING"
 	// FINISHED RUNNING->FINISHED.
 	FINISHED = "FINISHED"
 	// FAILED RUNNING->FAILED.
 	FAILED = "FAILED"
+	// TIMEOUT RUNNING->TIMEOUT, the attempt exceeded the timeout of task.
+	TIMEOUT = "TIMEOUT"
+	// SKIPPED NEW->SKIPPED, the run is not executed, the reason is in message.
+	SKIPPED = "SKIPPED"
 )
 
+// TriggerType defines how the run is triggered.
+type TriggerType string
+
+const (
+	// SCHEDULED triggered by the scheduler when the task expired.
+	SCHEDULED TriggerType = "SCHEDULED"
+	// MANUAL triggered by the operator.
+	MANUAL = "MANUAL"
+	// MISFIRE triggered by catching up the runs which were missed.
+	MISFIRE = "MISFIRE"
+)
+
+var (
+	// ErrIllegalTransition the transition is not allowed by the lifecycle.
+	ErrIllegalTransition = errors.New("illegal transition")
+	// ErrLostTransition the record has been moved by others meanwhile.
+	ErrLostTransition = errors.New("lost transition")
+
+	// transitions the lifecycle of record, from -> allowed to.
+	transitions = map[Status][]Status{
+		NEW:      {RUNNABLE, FAILED, SKIPPED},
+		RUNNABLE: {RUNNING, FAILED},
+		RUNNING:  {RUNNABLE, FINISHED, FAILED, TIMEOUT},
+	}
+)
+
+// TransitionError is returned when the record can't be moved, it wraps
+// ErrIllegalTransition or ErrLostTransition.
+type TransitionError struct {
+	ID   uint64
+	From Status
+	To   Status
+	Err  error
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("record %d %s -> %s: %v", e.ID, e.From, e.To, e.Err)
+}
+
+// Unwrap returns ErrIllegalTransition or ErrLostTransition.
+func (e *TransitionError) Unwrap() error {
+	return e.Err
+}
+
+// CanTransit returns true if the lifecycle allows moving from to.
+func CanTransit(from, to Status) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
 // SchedulingRecord is an object representing the database table.
 type SchedulingRecord struct {
-	ID        uint64 `gorm:"primaryKey,autoIncrement" json:"id" toml:"id" yaml:"id"`
-	TaskID    uint64 `gorm:"column:task_id" json:"task_id" toml:"task_id" yaml:"task_id"`
-	Status    Status `gorm:"column:status" json:"status" toml:"status" yaml:"status"`
-	Message   string `gorm:"column:message" json:"message" toml:"message" yaml:"message"`
-	DeletedAt uint64 `gorm:"column:deleted_at" json:"deleted_at" toml:"deleted_at" yaml:"deleted_at"`
-	CreatedAt uint64 `gorm:"autoCreateTime:nano" json:"created_at" toml:"created_at" yaml:"created_at"`
-	CreatedBy string `gorm:"column:created_by" json:"created_by,omitempty" toml:"created_by" yaml:"created_by,omitempty"`
-	UpdatedAt uint64 `gorm:"autoUpdateTime:nano" json:"updated_at" toml:"updated_at" yaml:"updated_at"`
-	UpdatedBy string `gorm:"column:updated_by" json:"updated_by,omitempty" toml:"updated_by" yaml:"updated_by,omitempty"`
+	ID     uint64 `gorm:"primaryKey,autoIncrement" json:"id" toml:"id" yaml:"id"`
+	TaskID uint64 `gorm:"column:task_id" json:"task_id" toml:"task_id" yaml:"task_id"`
+	// WorkflowRunID the run of workflow which the record belongs to, every
+	// task has at most one record in a run.
+	WorkflowRunID *uint64     `gorm:"column:workflow_run_id" json:"workflow_run_id,omitempty" toml:"workflow_run_id" yaml:"workflow_run_id,omitempty"`
+	TriggerType   TriggerType `gorm:"column:trigger_type;default:SCHEDULED" json:"trigger_type" toml:"trigger_type" yaml:"trigger_type"`
+	Status        Status      `gorm:"column:status" json:"status" toml:"status" yaml:"status"`
+	Attempt       int         `gorm:"column:attempt;default:1" json:"attempt" toml:"attempt" yaml:"attempt"`
+	DeadlineAt    uint64      `gorm:"column:deadline_at" json:"deadline_at" toml:"deadline_at" yaml:"deadline_at"`
+	// NextAttemptAt the time of the pending retry while the record is
+	// RUNNABLE, so that it can be resumed by others if the node crashed.
+	NextAttemptAt uint64 `gorm:"column:next_attempt_at" json:"next_attempt_at,omitempty" toml:"next_attempt_at" yaml:"next_attempt_at,omitempty"`
+	// NodeID the node which holds the pending retry, the others only resume
+	// it once the node is not alive.
+	NodeID    string         `gorm:"column:node_id" json:"node_id,omitempty" toml:"node_id" yaml:"node_id,omitempty"`
+	Overrides datatypes.JSON `gorm:"type:json;column:overrides" json:"overrides,omitempty" toml:"overrides" yaml:"overrides,omitempty"`
+	Message   string         `gorm:"column:message" json:"message" toml:"message" yaml:"message"`
+	DeletedAt uint64         `gorm:"column:deleted_at" json:"deleted_at" toml:"deleted_at" yaml:"deleted_at"`
+	CreatedAt uint64         `gorm:"autoCreateTime:nano" json:"created_at" toml:"created_at" yaml:"created_at"`
+	CreatedBy string         `gorm:"column:created_by" json:"created_by,omitempty" toml:"created_by" yaml:"created_by,omitempty"`
+	UpdatedAt uint64         `gorm:"autoUpdateTime:nano" json:"updated_at" toml:"updated_at" yaml:"updated_at"`
+	UpdatedBy string         `gorm:"column:updated_by" json:"updated_by,omitempty" toml:"updated_by" yaml:"updated_by,omitempty"`
 }
 
 // SchedulingRecordColumns table field name.
 var SchedulingRecordColumns = struct {
-	ID        string
-	TaskID    string
-	Status    string
-	Message   string
-	DeletedAt string
-	CreatedAt string
-	CreatedBy string
-	UpdatedAt string
-	UpdatedBy string
+	ID            string
+	TaskID        string
+	WorkflowRunID string
+	TriggerType   string
+	Status        string
+	Attempt       string
+	DeadlineAt    string
+	NextAttemptAt string
+	NodeID        string
+	Overrides     string
+	Message       string
+	DeletedAt     string
+	CreatedAt     string
+	CreatedBy     string
+	UpdatedAt     string
+	UpdatedBy     string
 }{
-	ID:        "id",
-	TaskID:    "task_id",
-	Status:    "status",
-	Message:   "message",
-	DeletedAt: "deleted_at",
-	CreatedAt: "created_at",
-	CreatedBy: "created_by",
-	UpdatedAt: "updated_at",
-	UpdatedBy: "updated_by",
+	ID:            "id",
+	TaskID:        "task_id",
+	WorkflowRunID: "workflow_run_id",
+	TriggerType:   "trigger_type",
+	Status:        "status",
+	Attempt:       "attempt",
+	DeadlineAt:    "deadline_at",
+	NextAttemptAt: "next_attempt_at",
+	NodeID:        "node_id",
+	Overrides:     "overrides",
+	Message:       "message",
+	DeletedAt:     "deleted_at",
+	CreatedAt:     "created_at",
+	CreatedBy:     "created_by",
+	UpdatedAt:     "updated_at",
+	UpdatedBy:     "updated_by",
 }
 
 // Tabler defines the table name.
@@ -180,3 +267,97 @@ func PaginateQuery(p *models.Pagination) (models.Response, error) {
 
 	return response, nil
 }
+
+// Transition moves the record from status to another one with message, the
+// message will not be changed if it's empty.
+func Transition(id uint64, from, to Status, message string) error {
+	values := make(map[string]interface{}, 1)
+	if message != "" {
+		values[SchedulingRecordColumns.Message] = message
+	}
+	return TransitionWith(id, from, to, values)
+}
+
+// TransitionWith moves the record from status to another one with extra
+// values by a conditional update, so that only one of the concurrent nodes
+// wins, others get ErrLostTransition.
+func TransitionWith(id uint64, from, to Status, values map[string]interface{}) error {
+	if !CanTransit(from, to) {
+		return &TransitionError{ID: id, From: from, To: to, Err: ErrIllegalTransition}
+	}
+	updates := make(map[string]interface{}, len(values)+1)
+	for k, v := range values {
+		updates[k] = v
+	}
+	updates[SchedulingRecordColumns.Status] = to
+
+	db := galaxyDB.GetDB()
+	tx := db.Model(&SchedulingRecord{}).
+		Where("id = ?", id).
+		Where("status = ?", from).
+		Updates(updates)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return &TransitionError{ID: id, From: from, To: to, Err: ErrLostTransition}
+	}
+	return nil
+}
+
+// GetOverdueRunning returns at most limit RUNNING records whose deadline is
+// before the specific time.
+func GetOverdueRunning(before uint64, limit int) ([]SchedulingRecord, error) {
+	db := galaxyDB.GetDB()
+	var records []SchedulingRecord
+	err := db.Where("status = ?", RUNNING).
+		Where("deadline_at > ? AND deadline_at < ?", 0, before).
+		Where("deleted_at = ?", 0).
+		Limit(limit).
+		Find(&records).Error
+	return records, err
+}
+
+// GetDueRetries returns at most limit RUNNABLE records whose pending retry
+// is due before the specific time, and whose node is not one of the alive
+// ones.
+func GetDueRetries(before uint64, alive []string, limit int) ([]SchedulingRecord, error) {
+	db := galaxyDB.GetDB()
+	var records []SchedulingRecord
+	tx := db.Where("status = ?", RUNNABLE).
+		Where("next_attempt_at > ? AND next_attempt_at < ?", 0, before).
+		Where("deleted_at = ?", 0)
+	if len(alive) > 0 {
+		tx = tx.Where("node_id NOT IN ?", alive)
+	}
+	err := tx.Limit(limit).Find(&records).Error
+	return records, err
+}
+
+// ClaimRetry moves the next attempt of the RUNNABLE record from old to next
+// and hands it over from the node owner to node by a conditional update,
+// returns false if it has been claimed or moved by others meanwhile.
+func ClaimRetry(id, old, next uint64, owner, node string) (bool, error) {
+	db := galaxyDB.GetDB()
+	tx := db.Model(&SchedulingRecord{}).
+		Where("id = ?", id).
+		Where("status = ?", RUNNABLE).
+		Where("next_attempt_at = ?", old).
+		Where("node_id = ?", owner).
+		Updates(map[string]interface{}{
+			SchedulingRecordColumns.NextAttemptAt: next,
+			SchedulingRecordColumns.NodeID:        node,
+		})
+	return tx.RowsAffected > 0, tx.Error
+}
+
+// GetByWorkflowRun returns the records of the workflow run.
+func GetByWorkflowRun(runID uint64) ([]SchedulingRecord, error) {
+	db := galaxyDB.GetDB()
+	var records []SchedulingRecord
+	err := db.Where("workflow_run_id = ?", runID).
+		Where("deleted_at = ?", 0).
+		Order("id").
+		Find(&records).Error
+	return records, err
+}
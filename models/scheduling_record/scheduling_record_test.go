@@ -1,6 +1,7 @@
 package schedulingrecord
 
 import (
+	"errors"
 	"os"
 	"testing"
 
@@ -235,3 +236,77 @@ func TestPaginateQuery(t *testing.T) {
 	assert.EqualValues(t, 3, len(res.Data.([]SchedulingRecord)), "data size error")
 	assert.EqualValues(t, res.Data.([]SchedulingRecord)[0].ID, uint64(2), "ID error")
 }
+
+func TestCanTransit(t *testing.T) {
+	assert.True(t, CanTransit(NEW, RUNNABLE))
+	assert.True(t, CanTransit(RUNNABLE, RUNNING))
+	assert.True(t, CanTransit(RUNNING, RUNNABLE))
+	assert.True(t, CanTransit(RUNNING, FINISHED))
+	assert.True(t, CanTransit(RUNNING, TIMEOUT))
+	assert.False(t, CanTransit(NEW, RUNNING))
+	assert.False(t, CanTransit(FINISHED, RUNNING))
+	assert.False(t, CanTransit(FAILED, RUNNABLE))
+}
+
+func TestTransition(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	record := &SchedulingRecord{
+		TaskID: uint64(1),
+		Status: NEW,
+	}
+	Create(record)
+
+	err := Transition(record.ID, NEW, RUNNING, "")
+	assert.True(t, errors.Is(err, ErrIllegalTransition), "NEW->RUNNING should be illegal")
+
+	assert.Nil(t, Transition(record.ID, NEW, RUNNABLE, ""))
+
+	// two nodes move the record to RUNNING concurrently, only one wins.
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errs <- Transition(record.ID, RUNNABLE, RUNNING, "")
+		}()
+	}
+	first, second := <-errs, <-errs
+	assert.True(t, (first == nil) != (second == nil), "only one transition should win")
+	if first == nil {
+		first = second
+	}
+	assert.True(t, errors.Is(first, ErrLostTransition), "the other should be lost")
+
+	assert.Nil(t, Transition(record.ID, RUNNING, FINISHED, "done"))
+	tmp, _ := Get(record.ID)
+	assert.EqualValues(t, FINISHED, tmp.Status, "status error")
+	assert.EqualValues(t, "done", tmp.Message, "message error")
+}
+
+func TestGetDueRetries(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	due := &SchedulingRecord{TaskID: 1, Status: RUNNABLE, Attempt: 2, NextAttemptAt: 100, NodeID: "dead"}
+	held := &SchedulingRecord{TaskID: 1, Status: RUNNABLE, Attempt: 2, NextAttemptAt: 100, NodeID: "alive"}
+	later := &SchedulingRecord{TaskID: 1, Status: RUNNABLE, Attempt: 2, NextAttemptAt: 300, NodeID: "dead"}
+	fresh := &SchedulingRecord{TaskID: 1, Status: RUNNABLE}
+	for _, r := range []*SchedulingRecord{due, held, later, fresh} {
+		assert.Nil(t, Create(r))
+	}
+
+	records, err := GetDueRetries(200, []string{"alive"}, 10)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1, "the retry held by the alive node should be left")
+	assert.Equal(t, due.ID, records[0].ID)
+
+	ok, err := ClaimRetry(due.ID, 100, 250, "dead", "alive")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, _ = ClaimRetry(due.ID, 100, 250, "dead", "alive")
+	assert.False(t, ok, "claimed by others")
+	records, _ = GetDueRetries(300, []string{"alive"}, 10)
+	assert.Empty(t, records, "held by the node which claimed it")
+}
@@ -1,14 +1,30 @@
 package task
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	galaxyDB "github.com/galaxy-center/galaxy/lifecycle"
 	models "github.com/galaxy-center/galaxy/models"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+	"github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// mysqlDuplicateEntry the error number of violating an unique key.
+const mysqlDuplicateEntry = 1062
+
+// DelayCodePrefix the code of the task created by a delay message is the
+// prefix and its dedupe key, separates them from the codes of other tasks.
+const DelayCodePrefix = "delay:"
+
 // Type defines the type of task.
 type Type string
 
@@ -29,8 +45,53 @@ const (
 	ENABLED = "ENABLED"
 	// DISABLED discarded status.
 	DISABLED = "DISABLED"
+	// PAUSED suspended status, will be resumed to ENABLED.
+	PAUSED = "PAUSED"
+)
+
+var (
+	// ErrIllegalTransition the transition is not allowed by the lifecycle.
+	ErrIllegalTransition = errors.New("illegal transition")
+	// ErrLostTransition the task has been changed by others meanwhile.
+	ErrLostTransition = errors.New("lost transition")
+
+	// transitions the lifecycle of task, from -> allowed to.
+	transitions = map[Status][]Status{
+		PENDING:  {ENABLED, DISABLED},
+		ENABLED:  {PAUSED, DISABLED},
+		PAUSED:   {ENABLED, DISABLED},
+		DISABLED: {ENABLED},
+	}
 )
 
+// TransitionError is returned when the task can't be moved, it wraps
+// ErrIllegalTransition or ErrLostTransition.
+type TransitionError struct {
+	ID   uint64
+	From Status
+	To   Status
+	Err  error
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("task %d %s -> %s: %v", e.ID, e.From, e.To, e.Err)
+}
+
+// Unwrap returns ErrIllegalTransition or ErrLostTransition.
+func (e *TransitionError) Unwrap() error {
+	return e.Err
+}
+
+// CanTransit returns true if the lifecycle allows moving from to.
+func CanTransit(from, to Status) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
 // SchedulingCategory categories.
 type SchedulingCategory string
 
@@ -41,7 +102,8 @@ const (
 	MULTIPLE = "MULTIPLE"
 )
 
-// Executor defines.
+// Executor defines, the built-ins are listed below, others can be added by
+// executor.Register.
 type Executor string
 
 const (
@@ -51,8 +113,128 @@ const (
 	RPC = "RPC"
 	// HTTP from http app
 	HTTP = "HTTP"
+	// SCRIPT from starlark script
+	SCRIPT = "SCRIPT"
+	// COMMAND from local command
+	COMMAND = "COMMAND"
+)
+
+// MisfirePolicy defines what to do with the runs which were missed, e.g.
+// Galaxy was down when the task expired.
+type MisfirePolicy string
+
+const (
+	// FIRE_ONCE fires once for all the missed runs.
+	FIRE_ONCE MisfirePolicy = "FIRE_ONCE"
+	// FIRE_ALL fires every missed run.
+	FIRE_ALL = "FIRE_ALL"
+	// SKIP skips the missed runs, and waits for the next one.
+	SKIP = "SKIP"
+)
+
+// OverlapPolicy defines what to do with the new run while the task has
+// reached its max concurrency on the node. The concurrency is limited per
+// node, e.g. every node runs a shard of MULTIPLE task at once.
+type OverlapPolicy string
+
+const (
+	// OVERLAP_SKIP skips the new run.
+	OVERLAP_SKIP OverlapPolicy = "SKIP"
+	// OVERLAP_QUEUE queues the new run until one of the runs on the node is
+	// over.
+	OVERLAP_QUEUE = "QUEUE"
+	// OVERLAP_REPLACE cancels the runs on the node, then executes the new one.
+	OVERLAP_REPLACE = "REPLACE"
+)
+
+const (
+	// MinPriority the default priority of task.
+	MinPriority = 0
+	// MaxPriority the runs of higher priority take the workers of node first.
+	MaxPriority = 9
+)
+
+// Backoff defines how the retry delay grows.
+type Backoff string
+
+const (
+	// FIXED retries with the same delay.
+	FIXED Backoff = "FIXED"
+	// EXPONENTIAL retries with the doubled delay of last attempt.
+	EXPONENTIAL = "EXPONENTIAL"
 )
 
+// RetryPolicy defines how the failed run will be retried.
+type RetryPolicy struct {
+	// MaxAttempts includes the first one, 0 means never retry.
+	MaxAttempts int     `gorm:"column:retry_max_attempts" json:"max_attempts" toml:"max_attempts" yaml:"max_attempts"`
+	Backoff     Backoff `gorm:"column:retry_backoff" json:"backoff,omitempty" toml:"backoff" yaml:"backoff,omitempty"`
+	// Delay the base delay, unit is seconds.
+	Delay int `gorm:"column:retry_delay" json:"delay" toml:"delay" yaml:"delay"`
+	// MaxDelay unit is seconds, 0 means unlimited.
+	MaxDelay int `gorm:"column:retry_max_delay" json:"max_delay" toml:"max_delay" yaml:"max_delay"`
+	// Jitter ratio from 0 to 1, delay will be randomized in [delay*(1-jitter), delay*(1+jitter)].
+	Jitter float64 `gorm:"column:retry_jitter" json:"jitter" toml:"jitter" yaml:"jitter"`
+	// StatusCodes separated by comma, e.g. `500,502,503`, empty means any.
+	StatusCodes string `gorm:"column:retry_status_codes" json:"status_codes,omitempty" toml:"status_codes" yaml:"status_codes,omitempty"`
+}
+
+// ParseStatusCodes returns the retryable status codes.
+func (p RetryPolicy) ParseStatusCodes() ([]int, error) {
+	var codes []int
+	for _, v := range strings.Split(p.StatusCodes, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		code, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code %q", v)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// IDs the ids of tasks, which is stored as a JSON array.
+type IDs []uint64
+
+// Contains returns true if id is one of ids.
+func (ids IDs) Contains(id uint64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements driver.Valuer, empty ids is stored as null.
+func (ids IDs) Value() (driver.Value, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal([]uint64(ids))
+	return string(b), err
+}
+
+// Scan implements sql.Scanner.
+func (ids *IDs) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*ids = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unsupported ids %T", value)
+	}
+	return json.Unmarshal(b, (*[]uint64)(ids))
+}
+
 // Task is an object representing the database table.
 type Task struct {
 	ID                 uint64             `gorm:"primaryKey,autoIncrement" json:"id" toml:"id" yaml:"id"`
@@ -61,15 +243,34 @@ type Task struct {
 	Type               Type               `gorm:"embedded,column:type" json:"type" toml:"type" yaml:"type"`
 	Status             Status             `gorm:"column:status" json:"status" toml:"status" yaml:"status"`
 	ExpiredAt          uint64             `gorm:"column:expired_at" json:"expired_at" toml:"expired_at" yaml:"expired_at"`
+	FiredAt            uint64             `gorm:"column:fired_at" json:"fired_at,omitempty" toml:"fired_at" yaml:"fired_at,omitempty"`
 	Cron               string             `gorm:"column:cron" json:"cron,omitempty" toml:"cron" yaml:"cron,omitempty"`
+	Timezone           string             `gorm:"column:timezone" json:"timezone,omitempty" toml:"timezone" yaml:"timezone,omitempty"`
+	CalendarID         *uint64            `gorm:"column:calendar_id" json:"calendar_id,omitempty" toml:"calendar_id" yaml:"calendar_id,omitempty"`
 	Timeout            int                `gorm:"column:timeout" json:"timeout" toml:"timeout" yaml:"timeout"`
 	SchedulingCategory SchedulingCategory `gorm:"column:scheduling_category" json:"scheduling_category" toml:"scheduling_category" yaml:"scheduling_category"`
 	Executor           Executor           `gorm:"column:executor" json:"executor" toml:"executor" yaml:"executor"`
+	RetryPolicy        RetryPolicy        `gorm:"embedded" json:"retry_policy" toml:"retry_policy" yaml:"retry_policy"`
+	MisfirePolicy      MisfirePolicy      `gorm:"column:misfire_policy;default:FIRE_ONCE" json:"misfire_policy" toml:"misfire_policy" yaml:"misfire_policy"`
+	MaxConcurrency     int                `gorm:"column:max_concurrency" json:"max_concurrency" toml:"max_concurrency" yaml:"max_concurrency"`
+	OverlapPolicy      OverlapPolicy      `gorm:"column:overlap_policy;default:SKIP" json:"overlap_policy" toml:"overlap_policy" yaml:"overlap_policy"`
+	Priority           int                `gorm:"column:priority" json:"priority" toml:"priority" yaml:"priority"`
+	StartAt            uint64             `gorm:"column:start_at" json:"start_at" toml:"start_at" yaml:"start_at"`
+	EndAt              uint64             `gorm:"column:end_at" json:"end_at" toml:"end_at" yaml:"end_at"`
+	MaxRuns            int                `gorm:"column:max_runs" json:"max_runs" toml:"max_runs" yaml:"max_runs"`
+	Runs               int                `gorm:"column:runs" json:"runs" toml:"runs" yaml:"runs"`
+	DisabledReason     string             `gorm:"column:disabled_reason" json:"disabled_reason,omitempty" toml:"disabled_reason" yaml:"disabled_reason,omitempty"`
+	TaskConfigID       *uint64            `gorm:"column:task_config_id" json:"task_config_id,omitempty" toml:"task_config_id" yaml:"task_config_id,omitempty"`
+	WorkflowID         *uint64            `gorm:"column:workflow_id" json:"workflow_id,omitempty" toml:"workflow_id" yaml:"workflow_id,omitempty"`
+	Upstreams          IDs                `gorm:"type:json;column:upstreams" json:"upstreams,omitempty" toml:"upstreams" yaml:"upstreams,omitempty"`
 	DeletedAt          uint64             `gorm:"column:deleted_at" json:"deleted_at" toml:"deleted_at" yaml:"deleted_at"`
 	CreatedAt          uint64             `gorm:"autoCreateTime:nano" json:"created_at" toml:"created_at" yaml:"created_at"`
 	CreatedBy          string             `gorm:"column:created_by" json:"created_by,omitempty" toml:"created_by" yaml:"created_by,omitempty"`
 	UpdatedAt          uint64             `gorm:"autoUpdateTime:nano" json:"updated_at" toml:"updated_at" yaml:"updated_at"`
 	UpdatedBy          string             `gorm:"column:updated_by" json:"updated_by,omitempty" toml:"updated_by" yaml:"updated_by,omitempty"`
+
+	// TaskConfig belongs to the task, only be loaded by GetWithConfig.
+	TaskConfig *taskconfig.TaskConfig `gorm:"foreignKey:TaskConfigID" json:"task_config,omitempty" toml:"task_config" yaml:"task_config,omitempty"`
 }
 
 // TaskColumns table field name.
@@ -80,10 +281,31 @@ var TaskColumns = struct {
 	Type               string
 	Status             string
 	ExpiredAt          string
+	FiredAt            string
 	Cron               string
+	Timezone           string
+	CalendarID         string
 	Timeout            string
 	SchedulingCategory string
 	Executor           string
+	RetryMaxAttempts   string
+	RetryBackoff       string
+	RetryDelay         string
+	RetryMaxDelay      string
+	RetryJitter        string
+	RetryStatusCodes   string
+	MisfirePolicy      string
+	MaxConcurrency     string
+	OverlapPolicy      string
+	Priority           string
+	StartAt            string
+	EndAt              string
+	MaxRuns            string
+	Runs               string
+	DisabledReason     string
+	TaskConfigID       string
+	WorkflowID         string
+	Upstreams          string
 	DeletedAt          string
 	CreatedAt          string
 	CreatedBy          string
@@ -96,10 +318,31 @@ var TaskColumns = struct {
 	Type:               "type",
 	Status:             "status",
 	ExpiredAt:          "expired_at",
+	FiredAt:            "fired_at",
 	Cron:               "cron",
+	Timezone:           "timezone",
+	CalendarID:         "calendar_id",
 	Timeout:            "timeout",
 	SchedulingCategory: "scheduling_category",
 	Executor:           "executor",
+	RetryMaxAttempts:   "retry_max_attempts",
+	RetryBackoff:       "retry_backoff",
+	RetryDelay:         "retry_delay",
+	RetryMaxDelay:      "retry_max_delay",
+	RetryJitter:        "retry_jitter",
+	RetryStatusCodes:   "retry_status_codes",
+	MisfirePolicy:      "misfire_policy",
+	MaxConcurrency:     "max_concurrency",
+	OverlapPolicy:      "overlap_policy",
+	Priority:           "priority",
+	StartAt:            "start_at",
+	EndAt:              "end_at",
+	MaxRuns:            "max_runs",
+	Runs:               "runs",
+	DisabledReason:     "disabled_reason",
+	TaskConfigID:       "task_config_id",
+	WorkflowID:         "workflow_id",
+	Upstreams:          "upstreams",
 	DeletedAt:          "deleted_at",
 	CreatedAt:          "created_at",
 	CreatedBy:          "created_by",
@@ -107,6 +350,20 @@ var TaskColumns = struct {
 	UpdatedBy:          "updated_by",
 }
 
+// IsRoot returns true if the task starts the runs of its workflow.
+func (t *Task) IsRoot() bool {
+	return t.WorkflowID != nil && len(t.Upstreams) == 0
+}
+
+// Location returns the IANA timezone which the cron is evaluated in, it's
+// the local timezone of node if absent.
+func (t *Task) Location() (*time.Location, error) {
+	if t.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(t.Timezone)
+}
+
 // Tabler defines the table name.
 type Tabler interface {
 	TableName() string
@@ -138,10 +395,56 @@ func (t *Task) AfterCreate(tx *gorm.DB) (err error) {
 // Create a single Task to db by *gorm.DB
 func Create(task *Task) error {
 	db := galaxyDB.GetDB()
-	err := db.Create(task).Error
+	err := db.Omit(clause.Associations).Create(task).Error
 	return err
 }
 
+// CreateWithConfig creates the task with its inline config in the same transaction.
+func CreateWithConfig(task *Task) error {
+	if task.TaskConfig == nil {
+		return Create(task)
+	}
+	db := galaxyDB.GetDB()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(task.TaskConfig).Error; err != nil {
+			return err
+		}
+		task.TaskConfigID = &task.TaskConfig.ID
+		return tx.Omit(clause.Associations).Create(task).Error
+	})
+}
+
+// CreateBatchWithConfig creates the tasks with their inline configs by
+// multi-row inserts in the same transaction, all the tasks should have config.
+func CreateBatchWithConfig(tasks []*Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	db := galaxyDB.GetDB()
+	return db.Transaction(func(tx *gorm.DB) error {
+		configs := make([]*taskconfig.TaskConfig, len(tasks))
+		for i, t := range tasks {
+			if t.TaskConfig == nil {
+				return fmt.Errorf("config of task %s is required", t.Code)
+			}
+			configs[i] = t.TaskConfig
+		}
+		if err := tx.Create(&configs).Error; err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			t.TaskConfigID = &t.TaskConfig.ID
+		}
+		return tx.Omit(clause.Associations).Create(&tasks).Error
+	})
+}
+
+// IsDuplicated returns true if err is caused by the unique code.
+func IsDuplicated(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && me.Number == mysqlDuplicateEntry
+}
+
 // BeforeUpdate do somethings, e.g. updating the updated_at value.
 func (t *Task) BeforeUpdate(tx *gorm.DB) (err error) {
 	t.UpdatedAt = uint64(time.Now().UnixNano())
@@ -159,7 +462,7 @@ func (t *Task) AfterUpdate(tx *gorm.DB) (err error) {
 // Note: all the fields will be updated to db, includes default value.
 func Save(task *Task) error {
 	db := galaxyDB.GetDB()
-	err := db.Save(task).Error
+	err := db.Omit(clause.Associations).Save(task).Error
 	return err
 }
 
@@ -168,7 +471,7 @@ func Save(task *Task) error {
 // 只能保存非零字段
 func Updates(task *Task) error {
 	db := galaxyDB.GetDB()
-	err := db.Model(task).Updates(task).Error
+	err := db.Model(task).Omit(clause.Associations).Updates(task).Error
 	return err
 }
 
@@ -208,6 +511,32 @@ func Get(id uint64) (*Task, error) {
 	return &task, nil
 }
 
+// GetWithConfig returns the task with its config by specific id.
+func GetWithConfig(id uint64) (*Task, error) {
+	db := galaxyDB.GetDB()
+	var task Task
+	if err := db.Preload("TaskConfig").First(&task, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetByCode returns the task by specific code.
+func GetByCode(code string) (*Task, error) {
+	db := galaxyDB.GetDB()
+	var task Task
+	if err := db.Where("code = ?", code).First(&task).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &task, nil
+}
+
 // GetExcludeDeleted returns the task that excludes inactived by specific id.
 func GetExcludeDeleted(id uint64) (*Task, error) {
 	db := galaxyDB.GetDB()
@@ -238,3 +567,142 @@ func PaginateQuery(p *models.Pagination) (models.Response, error) {
 
 	return response, nil
 }
+
+// GetEnabledBetween returns the enabled tasks that excludes inactived whose
+// expired_at falls into [left, right).
+func GetEnabledBetween(left, right uint64) ([]Task, error) {
+	db := galaxyDB.GetDB()
+	var tasks []Task
+	err := db.Where("status = ?", ENABLED).
+		Where("deleted_at = ?", 0).
+		Where("expired_at >= ? AND expired_at < ?", left, right).
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// GetEnabledOverdue returns the enabled tasks that excludes inactived whose
+// expired_at is before the time, the earliest first.
+func GetEnabledOverdue(before uint64, limit int) ([]Task, error) {
+	db := galaxyDB.GetDB()
+	var tasks []Task
+	err := db.Where("status = ?", ENABLED).
+		Where("deleted_at = ?", 0).
+		Where("expired_at > 0 AND expired_at < ?", before).
+		Order("expired_at").
+		Limit(limit).
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// GetByWorkflow returns the tasks that excludes inactived in the workflow.
+func GetByWorkflow(workflowID uint64) ([]Task, error) {
+	db := galaxyDB.GetDB()
+	var tasks []Task
+	err := db.Where("workflow_id = ?", workflowID).
+		Where("deleted_at = ?", 0).
+		Order("id").
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// CountByCalendar returns the number of tasks that excludes inactived which
+// reference the calendar.
+func CountByCalendar(calendarID uint64) (int64, error) {
+	db := galaxyDB.GetDB()
+	var count int64
+	err := db.Model(&Task{}).
+		Where("calendar_id = ?", calendarID).
+		Where("deleted_at = ?", 0).
+		Count(&count).Error
+	return count, err
+}
+
+// PurgeDelays removes the tasks of delay messages which have been deleted
+// softly before the specific time permanently, at most limit ones at a time.
+// Their scheduling records are removed along with them, and so are their
+// configs unless they are referenced by other tasks. Returns the number of
+// purged tasks.
+func PurgeDelays(before uint64, limit int) (int, error) {
+	db := galaxyDB.GetDB()
+	var tasks []Task
+	err := db.Select("id", "task_config_id").
+		Where("code LIKE ?", DelayCodePrefix+"%").
+		Where("type = ?", DelayQueue).
+		Where("deleted_at > ? AND deleted_at < ?", 0, before).
+		Limit(limit).
+		Find(&tasks).Error
+	if err != nil || len(tasks) == 0 {
+		return 0, err
+	}
+	ids := make([]uint64, 0, len(tasks))
+	var configIDs []uint64
+	for _, t := range tasks {
+		ids = append(ids, t.ID)
+		if t.TaskConfigID != nil {
+			configIDs = append(configIDs, *t.TaskConfigID)
+		}
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id IN ?", ids).Delete(&schedulingrecord.SchedulingRecord{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id IN ?", ids).Delete(&Task{}).Error; err != nil {
+			return err
+		}
+		if len(configIDs) == 0 {
+			return nil
+		}
+		return tx.Where("id IN ?", configIDs).
+			Where("NOT EXISTS (SELECT 1 FROM tasks WHERE tasks.task_config_id = task_configs.id)").
+			Delete(&taskconfig.TaskConfig{}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// Fire updates expired_at of the task to next and counts the fired runs only
+// if expired_at still equals to old, returns false if the task has been
+// changed meanwhile. The old one is kept as fired_at if any run is fired.
+func Fire(id, old, next uint64, runs int) (bool, error) {
+	values := map[string]interface{}{
+		TaskColumns.ExpiredAt: next,
+		TaskColumns.Runs:      gorm.Expr("runs + ?", runs),
+	}
+	if runs > 0 {
+		values[TaskColumns.FiredAt] = old
+	}
+	db := galaxyDB.GetDB()
+	tx := db.Model(&Task{}).
+		Where("id = ?", id).
+		Where("expired_at = ?", old).
+		Updates(values)
+	return tx.RowsAffected > 0, tx.Error
+}
+
+// Transition moves the task from status to another one with extra values by
+// a conditional update, returns *TransitionError if it's illegal or lost.
+func Transition(id uint64, from, to Status, values map[string]interface{}) error {
+	if !CanTransit(from, to) {
+		return &TransitionError{ID: id, From: from, To: to, Err: ErrIllegalTransition}
+	}
+	updates := make(map[string]interface{}, len(values)+1)
+	for k, v := range values {
+		updates[k] = v
+	}
+	updates[TaskColumns.Status] = to
+
+	db := galaxyDB.GetDB()
+	tx := db.Model(&Task{}).
+		Where("id = ?", id).
+		Where("status = ?", from).
+		Updates(updates)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return &TransitionError{ID: id, From: from, To: to, Err: ErrLostTransition}
+	}
+	return nil
+}
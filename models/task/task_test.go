@@ -1,6 +1,7 @@
 package task
 
 import (
+	"errors"
 	"os"
 	"strconv"
 	"testing"
@@ -10,7 +11,9 @@ import (
 	db "github.com/galaxy-center/galaxy/lifecycle"
 	migrateProvider "github.com/galaxy-center/galaxy/migrate"
 	models "github.com/galaxy-center/galaxy/models"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
 )
 
 func init() {
@@ -48,6 +51,38 @@ func TestCreate(t *testing.T) {
 	assert.EqualValues(t, uint64(100), exist.ExpiredAt, "err")
 }
 
+func TestCreateWithConfig(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	task := &Task{
+		Name:               "test",
+		Code:               "codeA",
+		Type:               DelayQueue,
+		Status:             ENABLED,
+		ExpiredAt:          100,
+		Timeout:            3600,
+		SchedulingCategory: SINGLETON,
+		Executor:           HTTP,
+		TaskConfig: &taskconfig.TaskConfig{
+			Headers: datatypes.JSON(`{"token":"abc"}`),
+			Content: datatypes.JSON(`{"url":"http://localhost"}`),
+		},
+	}
+	CreateWithConfig(task)
+	assert.NotNil(t, task.TaskConfigID, "task_config_id should be not null")
+
+	tmp, _ := Get(task.ID)
+	assert.NotNil(t, tmp, "tmp should be not null")
+	assert.Nil(t, tmp.TaskConfig, "config should not be loaded")
+
+	exist, _ := GetWithConfig(task.ID)
+	assert.NotNil(t, exist.TaskConfig, "config should be loaded")
+	assert.EqualValues(t, *task.TaskConfigID, exist.TaskConfig.ID, "config id error")
+	assert.JSONEq(t, `{"token":"abc"}`, string(exist.TaskConfig.Headers))
+}
+
 func TestSave(t *testing.T) {
 	m, _ := migrateProvider.BuildMigration()
 	migrateProvider.Up(m)
@@ -241,3 +276,131 @@ func TestPaginateQuery(t *testing.T) {
 	assert.EqualValues(t, res.Total, 1, "total error")
 	assert.EqualValues(t, res.Data.([]Task)[0].ID, uint64(6), "ID error")
 }
+
+func TestTransition(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	task := &Task{
+		Name:               "test",
+		Code:               "codeA",
+		Type:               DelayJob,
+		Status:             PENDING,
+		ExpiredAt:          100,
+		Timeout:            3600,
+		SchedulingCategory: SINGLETON,
+		Executor:           HTTP,
+	}
+	Create(task)
+
+	err := Transition(task.ID, PENDING, PAUSED, nil)
+	assert.True(t, errors.Is(err, ErrIllegalTransition), "PENDING->PAUSED should be illegal")
+
+	assert.Nil(t, Transition(task.ID, PENDING, ENABLED, map[string]interface{}{"expired_at": 200}))
+	tmp, _ := Get(task.ID)
+	assert.EqualValues(t, ENABLED, tmp.Status, "status error")
+	assert.EqualValues(t, 200, tmp.ExpiredAt, "expired_at error")
+
+	err = Transition(task.ID, PAUSED, ENABLED, nil)
+	assert.True(t, errors.Is(err, ErrLostTransition), "task is not PAUSED")
+
+	assert.Nil(t, Transition(task.ID, ENABLED, PAUSED, nil))
+	assert.Nil(t, Transition(task.ID, PAUSED, DISABLED, nil))
+	assert.False(t, CanTransit(DISABLED, PAUSED))
+}
+
+func TestCreateBatchWithConfig(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	tasks := make([]*Task, 4)
+	for i := range tasks {
+		code := DelayCodePrefix + strconv.Itoa(i)
+		if i == 3 {
+			code = "once"
+		}
+		tasks[i] = &Task{
+			Name:               "delay",
+			Code:               code,
+			Type:               DelayQueue,
+			Status:             ENABLED,
+			ExpiredAt:          100,
+			SchedulingCategory: SINGLETON,
+			Executor:           HTTP,
+			TaskConfig: &taskconfig.TaskConfig{
+				Content: datatypes.JSON(`{"url":"http://localhost/` + strconv.Itoa(i) + `"}`),
+			},
+		}
+	}
+	err := CreateBatchWithConfig(tasks)
+	assert.Nil(t, err)
+
+	for i, task := range tasks {
+		exist, _ := GetWithConfig(task.ID)
+		assert.NotNil(t, exist, "task should be created")
+		assert.Equal(t, "delay:"+strconv.Itoa(i), exist.Code)
+		assert.JSONEq(t, `{"url":"http://localhost/`+strconv.Itoa(i)+`"}`, string(exist.TaskConfig.Content))
+	}
+
+	byCode, _ := GetByCode("delay:1")
+	assert.EqualValues(t, tasks[1].ID, byCode.ID)
+
+	duplicated := &Task{
+		Name:               "delay",
+		Code:               "delay:1",
+		Type:               DelayQueue,
+		Status:             ENABLED,
+		SchedulingCategory: SINGLETON,
+		Executor:           HTTP,
+		TaskConfig:         &taskconfig.TaskConfig{Content: datatypes.JSON(`{}`)},
+	}
+	err = CreateBatchWithConfig([]*Task{duplicated})
+	assert.True(t, IsDuplicated(err), "code should be unique")
+}
+
+func TestPurgeDelays(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	tasks := make([]*Task, 4)
+	for i := range tasks {
+		code := DelayCodePrefix + strconv.Itoa(i)
+		if i == 3 {
+			code = "once"
+		}
+		tasks[i] = &Task{
+			Name:               "delay",
+			Code:               code,
+			Type:               DelayQueue,
+			Status:             ENABLED,
+			ExpiredAt:          100,
+			SchedulingCategory: SINGLETON,
+			Executor:           HTTP,
+			TaskConfig:         &taskconfig.TaskConfig{Content: datatypes.JSON(`{}`)},
+		}
+	}
+	assert.Nil(t, CreateBatchWithConfig(tasks))
+	assert.Nil(t, DeleteAt(tasks[0].ID))
+	assert.Nil(t, DeleteAt(tasks[1].ID))
+	assert.Nil(t, DeleteAt(tasks[3].ID))
+	before := uint64(time.Now().UnixNano())
+	assert.Nil(t, DeleteAt(tasks[2].ID))
+
+	n, err := PurgeDelays(before, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	for i, task := range tasks {
+		exist, _ := Get(task.ID)
+		tc, _ := taskconfig.Get(*task.TaskConfigID)
+		if i < 2 {
+			assert.Nil(t, exist, "deleted before the retention should be purged")
+			assert.Nil(t, tc, "config should be purged with task")
+		} else {
+			assert.NotNil(t, exist, "deleted within the retention or not a delay message should be kept")
+			assert.NotNil(t, tc)
+		}
+	}
+}
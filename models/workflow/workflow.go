@@ -0,0 +1,173 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	galaxyDB "github.com/galaxy-center/galaxy/lifecycle"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+	"gorm.io/gorm"
+)
+
+// ErrTaskTaken the task of node has been deleted or joined another workflow.
+var ErrTaskTaken = errors.New("task is deleted or in another workflow")
+
+// Workflow is an object representing the database table, its nodes are the
+// tasks which declare the upstream tasks.
+type Workflow struct {
+	ID        uint64 `gorm:"primaryKey,autoIncrement" json:"id" toml:"id" yaml:"id"`
+	Name      string `gorm:"column:name" json:"name" toml:"name" yaml:"name"`
+	DeletedAt uint64 `gorm:"column:deleted_at" json:"deleted_at" toml:"deleted_at" yaml:"deleted_at"`
+	CreatedAt uint64 `gorm:"autoCreateTime:nano" json:"created_at" toml:"created_at" yaml:"created_at"`
+	CreatedBy string `gorm:"column:created_by" json:"created_by,omitempty" toml:"created_by" yaml:"created_by,omitempty"`
+	UpdatedAt uint64 `gorm:"autoUpdateTime:nano" json:"updated_at" toml:"updated_at" yaml:"updated_at"`
+	UpdatedBy string `gorm:"column:updated_by" json:"updated_by,omitempty" toml:"updated_by" yaml:"updated_by,omitempty"`
+}
+
+// WorkflowColumns table field name.
+var WorkflowColumns = struct {
+	ID        string
+	Name      string
+	DeletedAt string
+	CreatedAt string
+	CreatedBy string
+	UpdatedAt string
+	UpdatedBy string
+}{
+	ID:        "id",
+	Name:      "name",
+	DeletedAt: "deleted_at",
+	CreatedAt: "created_at",
+	CreatedBy: "created_by",
+	UpdatedAt: "updated_at",
+	UpdatedBy: "updated_by",
+}
+
+// Run is an object representing the database table, a run of workflow
+// executes every node at most once.
+type Run struct {
+	ID          uint64                       `gorm:"primaryKey,autoIncrement" json:"id" toml:"id" yaml:"id"`
+	WorkflowID  uint64                       `gorm:"column:workflow_id" json:"workflow_id" toml:"workflow_id" yaml:"workflow_id"`
+	TriggerType schedulingrecord.TriggerType `gorm:"column:trigger_type;default:SCHEDULED" json:"trigger_type" toml:"trigger_type" yaml:"trigger_type"`
+	CreatedAt   uint64                       `gorm:"autoCreateTime:nano" json:"created_at" toml:"created_at" yaml:"created_at"`
+	CreatedBy   string                       `gorm:"column:created_by" json:"created_by,omitempty" toml:"created_by" yaml:"created_by,omitempty"`
+}
+
+// RunColumns table field name.
+var RunColumns = struct {
+	ID          string
+	WorkflowID  string
+	TriggerType string
+	CreatedAt   string
+	CreatedBy   string
+}{
+	ID:          "id",
+	WorkflowID:  "workflow_id",
+	TriggerType: "trigger_type",
+	CreatedAt:   "created_at",
+	CreatedBy:   "created_by",
+}
+
+// Tabler defines the table name.
+type Tabler interface {
+	TableName() string
+}
+
+// TableName 会将 Workflow 的表名重写为 `workflows`
+func (Workflow) TableName() string {
+	return "workflows"
+}
+
+// TableName 会将 Run 的表名重写为 `workflow_runs`
+func (Run) TableName() string {
+	return "workflow_runs"
+}
+
+// BeforeUpdate do somethings, e.g. updating the updated_at value.
+func (w *Workflow) BeforeUpdate(tx *gorm.DB) (err error) {
+	w.UpdatedAt = uint64(time.Now().UnixNano())
+	return
+}
+
+// CreateWithNodes creates the workflow and joins the tasks of nodes, task id
+// -> upstream task ids, in the same transaction. The tasks except the owner
+// are only fired by the workflow, so their expired_at is cleared.
+func CreateWithNodes(w *Workflow, nodes map[uint64]task.IDs) error {
+	db := galaxyDB.GetDB()
+	owner := Owner(nodes)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(w).Error; err != nil {
+			return err
+		}
+		for id, upstreams := range nodes {
+			values := map[string]interface{}{
+				task.TaskColumns.WorkflowID: w.ID,
+				task.TaskColumns.Upstreams:  upstreams,
+				task.TaskColumns.UpdatedBy:  w.CreatedBy,
+			}
+			if id != owner {
+				values[task.TaskColumns.ExpiredAt] = 0
+			}
+			res := tx.Model(&task.Task{}).
+				Where("id = ?", id).
+				Where("workflow_id IS NULL").
+				Where("deleted_at = ?", 0).
+				Updates(values)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return fmt.Errorf("task %d: %w", id, ErrTaskTaken)
+			}
+		}
+		return nil
+	})
+}
+
+// Owner returns the root of nodes which begins the runs of workflow by its
+// schedule, it's the one with the smallest id. The other roots are started
+// along with it, so that a run is began once.
+func Owner(nodes map[uint64]task.IDs) uint64 {
+	var owner uint64
+	for id, upstreams := range nodes {
+		if len(upstreams) == 0 && (owner == 0 || id < owner) {
+			owner = id
+		}
+	}
+	return owner
+}
+
+// Get returns the workflow by specific id.
+func Get(id uint64) (*Workflow, error) {
+	db := galaxyDB.GetDB()
+	var w Workflow
+	if err := db.First(&w, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &w, nil
+}
+
+// CreateRun a single Run to db.
+func CreateRun(run *Run) error {
+	db := galaxyDB.GetDB()
+	err := db.Create(run).Error
+	return err
+}
+
+// GetRun returns the run by specific id.
+func GetRun(id uint64) (*Run, error) {
+	db := galaxyDB.GetDB()
+	var run Run
+	if err := db.First(&run, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &run, nil
+}
@@ -0,0 +1,102 @@
+package workflow
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/galaxy-center/galaxy/config"
+	db "github.com/galaxy-center/galaxy/lifecycle"
+	migrateProvider "github.com/galaxy-center/galaxy/migrate"
+	"github.com/galaxy-center/galaxy/models/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	config.SetTestMode(true)
+	db.Init()
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	os.Exit(code)
+}
+
+func TestCreateWithNodes(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	ids := createTasks(t, "export", "transform", "notify")
+
+	w := &Workflow{Name: "etl", CreatedBy: "tester"}
+	err := CreateWithNodes(w, map[uint64]task.IDs{
+		ids[0]: nil,
+		ids[1]: {ids[0]},
+		ids[2]: {ids[1]},
+	})
+	assert.Nil(t, err)
+
+	tasks, err := task.GetByWorkflow(w.ID)
+	assert.Nil(t, err)
+	assert.Len(t, tasks, 3)
+	assert.True(t, tasks[0].IsRoot())
+	assert.EqualValues(t, 100, tasks[0].ExpiredAt)
+	assert.Equal(t, task.IDs{ids[0]}, tasks[1].Upstreams)
+	assert.EqualValues(t, 0, tasks[1].ExpiredAt, "fired by upstreams only")
+
+	again := &Workflow{Name: "again"}
+	err = CreateWithNodes(again, map[uint64]task.IDs{ids[0]: nil})
+	assert.True(t, errors.Is(err, ErrTaskTaken))
+	exist, _ := Get(again.ID)
+	assert.Nil(t, exist, "rolled back")
+
+	run := &Run{WorkflowID: w.ID, CreatedBy: "tester"}
+	assert.Nil(t, CreateRun(run))
+	got, err := GetRun(run.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, w.ID, got.WorkflowID)
+}
+
+func TestCreateWithTwoRoots(t *testing.T) {
+	m, _ := migrateProvider.BuildMigration()
+	migrateProvider.Up(m)
+	defer migrateProvider.Drop(m)
+
+	ids := createTasks(t, "orders", "users", "report")
+	nodes := map[uint64]task.IDs{
+		ids[0]: nil,
+		ids[1]: nil,
+		ids[2]: {ids[0], ids[1]},
+	}
+	assert.Equal(t, ids[0], Owner(nodes))
+
+	w := &Workflow{Name: "report", CreatedBy: "tester"}
+	assert.Nil(t, CreateWithNodes(w, nodes))
+	tasks, err := task.GetByWorkflow(w.ID)
+	assert.Nil(t, err)
+	assert.Len(t, tasks, 3)
+	assert.EqualValues(t, 100, tasks[0].ExpiredAt, "the owner keeps its schedule")
+	assert.True(t, tasks[1].IsRoot())
+	assert.EqualValues(t, 0, tasks[1].ExpiredAt, "started along with the owner")
+}
+
+// createTasks inserts the enabled one-shot tasks of codes, returns their ids.
+func createTasks(t *testing.T, codes ...string) []uint64 {
+	var ids []uint64
+	for _, code := range codes {
+		tk := &task.Task{
+			Name:               code,
+			Code:               code,
+			Type:               task.DelayQueue,
+			Status:             task.ENABLED,
+			ExpiredAt:          100,
+			Timeout:            60,
+			SchedulingCategory: task.SINGLETON,
+			Executor:           task.HTTP,
+		}
+		assert.Nil(t, task.Create(tk))
+		ids = append(ids, tk.ID)
+	}
+	return ids
+}
@@ -0,0 +1,124 @@
+package resources
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/galaxy-center/galaxy/commons"
+	"github.com/galaxy-center/galaxy/models/calendar"
+	services "github.com/galaxy-center/galaxy/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxICSSize the max size of the iCalendar file to import.
+const maxICSSize = 1 << 20
+
+// CreateCal create a calendar of excluded dates and time ranges.
+func CreateCal(c *gin.Context) {
+	var cal calendar.Calendar
+	if err := c.BindJSON(&cal); err != nil {
+		return
+	}
+	if ce := services.CreateCalendar(&cal); ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	log.WithField("calendar", cal.ID).Infof("inserted calendar %s", cal.Name)
+	c.JSON(http.StatusOK, commons.Success(cal))
+}
+
+// UpdateCal update the calendar of path id.
+func UpdateCal(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	var cal calendar.Calendar
+	if err := c.BindJSON(&cal); err != nil {
+		return
+	}
+	cal.ID = id
+	updated, ce := services.UpdateCalendar(&cal)
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(updated))
+}
+
+// ImportCal merges the events of the iCalendar file in body into the
+// calendar of path id.
+func ImportCal(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	data, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxICSSize))
+	if err != nil {
+		c.JSON(
+			http.StatusBadRequest,
+			commons.ErrorWithMessage(fmt.Sprintf("iCalendar is unreadable or larger than %d bytes.", maxICSSize)))
+		return
+	}
+	res, ce := services.ImportCalendar(id, data, c.Query("updated_by"))
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	log.WithField("calendar", id).Infof("imported %d events, ignored %d", res.Imported, res.Ignored)
+	c.JSON(http.StatusOK, commons.Success(res))
+}
+
+// GetCal get calendar by id.
+func GetCal(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	cal, ce := services.GetCalendar(id)
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(cal))
+}
+
+// DeleteCal delete the calendar which is not referenced by tasks.
+func DeleteCal(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if ce := services.DeleteCalendar(id); ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(id))
+}
+
+// PreviewT preview the next n fire times of task, the ones excluded by its
+// calendar are marked as skipped.
+func PreviewT(c *gin.Context) {
+	tid, ok := pathID(c)
+	if !ok {
+		return
+	}
+	var n int
+	if v := c.Query("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil {
+			c.JSON(
+				http.StatusBadRequest,
+				commons.ErrorWithMessage(fmt.Sprintf("n %s invalid.", v)))
+			return
+		}
+	}
+	slots, ce := services.PreviewTask(tid, n)
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(slots))
+}
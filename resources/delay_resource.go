@@ -0,0 +1,25 @@
+package resources
+
+import (
+	"net/http"
+
+	"github.com/galaxy-center/galaxy/commons"
+	services "github.com/galaxy-center/galaxy/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateD create a delay message, returns the task id of it.
+func CreateD(c *gin.Context) {
+	var m services.DelayMessage
+	if err := c.BindJSON(&m); err != nil {
+		return
+	}
+
+	r, ce := services.CreateDelay(&m)
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	log.WithField("task", r.TaskID).Debugf("inserted a delay message, duplicated: %v", r.Duplicated)
+	c.JSON(http.StatusOK, commons.Success(r))
+}
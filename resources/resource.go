@@ -1,9 +1,67 @@
 package resources
 
 import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/galaxy-center/galaxy/commons"
 	logger "github.com/galaxy-center/galaxy/log"
+	"github.com/galaxy-center/galaxy/models"
+	"github.com/galaxy-center/galaxy/utils"
+	"github.com/gin-gonic/gin"
 )
 
+const defaultPageSize = 10
+
 var (
 	log = logger.Get()
 )
+
+// pathID returns the path param `id`, writes 400 to response if it's invalid.
+func pathID(c *gin.Context) (uint64, bool) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, commons.ErrorWithMessage("params invalid"))
+		return 0, false
+	}
+	pid, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || pid <= 0 {
+		c.JSON(
+			http.StatusBadRequest,
+			commons.ErrorWithMessage(fmt.Sprintf("%s invalid.", id)))
+		return 0, false
+	}
+	return pid, true
+}
+
+// pagination builds the pagination from query, includes `f`(from), `t`(to),
+// `st`(start time) and `et`(end time), writes 400 to response if it's invalid.
+func pagination(c *gin.Context, attachment models.Attachment) (*models.Pagination, bool) {
+	var p = models.NewPagination()
+
+	from := utils.GetQueryIntOrDefault(c, "f", 0)
+	to := utils.GetQueryIntOrDefault(c, "t", from+defaultPageSize)
+	if from < 0 || from >= to {
+		c.JSON(
+			http.StatusBadRequest,
+			commons.ErrorWithMessage(fmt.Sprintf("pagination from %d more than to %d", from, to)))
+		return nil, false
+	}
+	p.SetPage(from/(to-from) + 1)
+	p.SetPageSize(to - from)
+
+	start := utils.GetQueryUint64OrDefault(c, "st", 0)
+	end := utils.GetQueryUint64OrDefault(c, "et", uint64(time.Now().UnixNano()))
+	if start > end {
+		c.JSON(
+			http.StatusBadRequest,
+			commons.ErrorWithMessage(fmt.Sprintf("pagination start time %d more than end time %d", start, end)))
+		return nil, false
+	}
+	attachment[models.PaginationColumns.TimeRange] = models.Uint64Range{}.Set(start, end)
+
+	p.SetAttachment(attachment)
+	return p, true
+}
@@ -0,0 +1,90 @@
+package resources
+
+import (
+	"net/http"
+
+	"github.com/galaxy-center/galaxy/commons"
+	"github.com/galaxy-center/galaxy/models"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	services "github.com/galaxy-center/galaxy/services"
+	"github.com/galaxy-center/galaxy/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateSR create func.
+func CreateSR(c *gin.Context) {
+	var r schedulingrecord.SchedulingRecord
+	c.BindJSON(&r)
+
+	if ce := services.CreateSchedulingRecord(&r); ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	log.WithField("record", r).Info("inserted a scheduling record")
+	c.JSON(http.StatusOK, commons.Success(r))
+}
+
+// UpdateSR update func.
+func UpdateSR(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	var r schedulingrecord.SchedulingRecord
+	c.BindJSON(&r)
+	r.ID = id
+
+	if ce := services.UpsertSchedulingRecord(&r); ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(r))
+}
+
+// DeleteSR deleted options.
+func DeleteSR(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	if _, ce := services.DeleteSchedulingRecord(id, true); ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(id))
+}
+
+// GetSR get scheduling record by id.
+func GetSR(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	r, ce := services.GetSchedulingRecord(id)
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(r))
+}
+
+// GetSRWith query pagination, filters by query `task_id` if present.
+func GetSRWith(c *gin.Context) {
+	attachment := models.Attachment{}
+	if tid := utils.GetQueryUint64OrDefault(c, "task_id", 0); tid > 0 {
+		attachment[schedulingrecord.SchedulingRecordColumns.TaskID] = tid
+	}
+	p, ok := pagination(c, attachment)
+	if !ok {
+		return
+	}
+	res, ce := services.GetSchedulingRecordsWith(p)
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(res))
+}
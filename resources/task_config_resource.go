@@ -0,0 +1,85 @@
+package resources
+
+import (
+	"net/http"
+
+	"github.com/galaxy-center/galaxy/commons"
+	"github.com/galaxy-center/galaxy/models"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+	services "github.com/galaxy-center/galaxy/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateTC create func.
+func CreateTC(c *gin.Context) {
+	var tc taskconfig.TaskConfig
+	c.BindJSON(&tc)
+
+	if ce := services.CreateTaskConfig(&tc); ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	log.WithField("config", tc).Info("inserted a task config")
+	c.JSON(http.StatusOK, commons.Success(tc))
+}
+
+// UpdateTC update func.
+func UpdateTC(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	var tc taskconfig.TaskConfig
+	c.BindJSON(&tc)
+	tc.ID = id
+
+	if ce := services.UpsertTaskConfig(&tc); ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(tc))
+}
+
+// DeleteTC deleted options.
+func DeleteTC(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	if _, ce := services.DeleteTaskConfig(id, true); ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(id))
+}
+
+// GetTC get task config by id.
+func GetTC(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	tc, ce := services.GetTaskConfig(id)
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(tc))
+}
+
+// GetTCWith query pagination.
+func GetTCWith(c *gin.Context) {
+	p, ok := pagination(c, models.Attachment{})
+	if !ok {
+		return
+	}
+	res, ce := services.GetTaskConfigsWith(p)
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(res))
+}
@@ -6,14 +6,17 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/galaxy-center/galaxy/commons"
 	"github.com/galaxy-center/galaxy/models"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
 	task "github.com/galaxy-center/galaxy/models/task"
 	services "github.com/galaxy-center/galaxy/services"
 	"github.com/galaxy-center/galaxy/utils"
 	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
 )
 
 // CreateT create func.
@@ -79,7 +82,7 @@ func DeleteT(c *gin.Context) {
 	c.JSON(http.StatusOK, commons.Success(tid))
 }
 
-// GetT get task by id.
+// GetT get task by id, embeds the config by `?expand=config`.
 func GetT(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
@@ -94,7 +97,13 @@ func GetT(c *gin.Context) {
 		return
 	}
 
-	t, ce := services.GetTask(tid)
+	withConfig := false
+	for _, e := range strings.Split(c.Query("expand"), ",") {
+		if e == "config" {
+			withConfig = true
+		}
+	}
+	t, ce := services.GetTask(tid, withConfig)
 	if ce != nil {
 		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
 		return
@@ -138,3 +147,83 @@ func GetTWith(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, commons.Success(res))
 }
+
+// GetTRecords query the scheduling records of task by pagination.
+func GetTRecords(c *gin.Context) {
+	tid, ok := pathID(c)
+	if !ok {
+		return
+	}
+	p, ok := pagination(c, models.Attachment{
+		schedulingrecord.SchedulingRecordColumns.TaskID: tid,
+	})
+	if !ok {
+		return
+	}
+	res, ce := services.GetSchedulingRecordsWith(p)
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(res))
+}
+
+// EnableT enable the task.
+func EnableT(c *gin.Context) {
+	transitT(c, services.EnableTask)
+}
+
+// DisableT disable the task.
+func DisableT(c *gin.Context) {
+	transitT(c, services.DisableTask)
+}
+
+// PauseT pause the task.
+func PauseT(c *gin.Context) {
+	transitT(c, services.PauseTask)
+}
+
+// ResumeT resume the task, the next fire time is recomputed from cron.
+func ResumeT(c *gin.Context) {
+	transitT(c, services.ResumeTask)
+}
+
+// triggerRequest the body of triggering a task.
+type triggerRequest struct {
+	CreatedBy string         `json:"created_by"`
+	Overrides datatypes.JSON `json:"overrides"`
+}
+
+// TriggerT run the task now, returns the id of scheduling record.
+func TriggerT(c *gin.Context) {
+	tid, ok := pathID(c)
+	if !ok {
+		return
+	}
+	var req triggerRequest
+	if err := c.BindJSON(&req); err != nil {
+		return
+	}
+	r, ce := services.TriggerTask(tid, req.CreatedBy, req.Overrides)
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	log.WithField("task", tid).WithField("record", r.ID).Infof("triggered task by %s", req.CreatedBy)
+	c.JSON(http.StatusOK, commons.Success(gin.H{"record_id": r.ID}))
+}
+
+// transitT moves the task of path id by transit.
+func transitT(c *gin.Context, transit func(uint64) (*task.Task, *commons.Error)) {
+	tid, ok := pathID(c)
+	if !ok {
+		return
+	}
+	t, ce := transit(tid)
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	log.WithField("task", tid).Infof("moved task to %s", t.Status)
+	c.JSON(http.StatusOK, commons.Success(t))
+}
@@ -0,0 +1,62 @@
+package resources
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/galaxy-center/galaxy/commons"
+	services "github.com/galaxy-center/galaxy/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateW create a workflow of the tasks.
+func CreateW(c *gin.Context) {
+	var req services.WorkflowRequest
+	if err := c.BindJSON(&req); err != nil {
+		return
+	}
+
+	w, ce := services.CreateWorkflow(&req)
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	log.WithField("workflow", w.ID).Infof("inserted a workflow of %d tasks", len(w.Nodes))
+	c.JSON(http.StatusOK, commons.Success(w))
+}
+
+// GetW get workflow by id with its nodes.
+func GetW(c *gin.Context) {
+	wid, ok := pathID(c)
+	if !ok {
+		return
+	}
+	w, ce := services.GetWorkflow(wid)
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(w))
+}
+
+// GetWRun get the run of workflow with the status of every node.
+func GetWRun(c *gin.Context) {
+	wid, ok := pathID(c)
+	if !ok {
+		return
+	}
+	runID, err := strconv.ParseUint(c.Param("runId"), 10, 64)
+	if err != nil || runID <= 0 {
+		c.JSON(
+			http.StatusBadRequest,
+			commons.ErrorWithMessage(fmt.Sprintf("run %s invalid.", c.Param("runId"))))
+		return
+	}
+	run, ce := services.GetWorkflowRun(wid, runID)
+	if ce != nil {
+		c.JSON(ce.Code, commons.ErrorWithMessage(ce.Format()))
+		return
+	}
+	c.JSON(http.StatusOK, commons.Success(run))
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/galaxy-center/galaxy/commons"
+	"github.com/galaxy-center/galaxy/middleware"
+	"github.com/galaxy-center/galaxy/models/calendar"
+	"github.com/galaxy-center/galaxy/models/task"
+	"github.com/galaxy-center/galaxy/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxCalendarNameLength fits the name of calendar, which is varchar(32).
+	maxCalendarNameLength = 32
+	// defaultPreviewSlots the number of fire times previewed by default.
+	defaultPreviewSlots = 10
+	// maxPreviewSlots the max number of fire times previewed at a time.
+	maxPreviewSlots = 100
+	// importYears the recurring events are imported within the years from now.
+	importYears = 2
+)
+
+// ImportResult the calendar after importing an iCalendar file.
+type ImportResult struct {
+	*calendar.Calendar
+	// Imported the number of events merged into the calendar.
+	Imported int `json:"imported"`
+	// Ignored the number of cancelled, zero-length events and the recurring
+	// ones which can't be expanded or don't occur from now on.
+	Ignored int `json:"ignored"`
+}
+
+// CreateCalendar creates the calendar of excluded dates and time ranges.
+func CreateCalendar(c *calendar.Calendar) *commons.Error {
+	if c.Name == "" {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("name is required")}
+	}
+	if ce := validateCalendar(c); ce != nil {
+		return ce
+	}
+	c.ID, c.DeletedAt = 0, 0
+	if c.UpdatedBy == "" {
+		c.UpdatedBy = c.CreatedBy
+	}
+	if err := calendar.Create(c); err != nil {
+		log.WithField("calendar", c.Name).Errorf("occurred exception when inserting calendar: %v", err)
+		return commons.StatusDBOperationAbnormal
+	}
+	return nil
+}
+
+// UpdateCalendar updates the name and timezone of calendar if present, the
+// exclusions are replaced if either of the dates and ranges is present. The
+// tasks which reference it follow the changes from their next fire time.
+func UpdateCalendar(c *calendar.Calendar) (*calendar.Calendar, *commons.Error) {
+	if _, ce := GetCalendar(c.ID); ce != nil {
+		return nil, ce
+	}
+	if ce := validateCalendar(c); ce != nil {
+		return nil, ce
+	}
+	values := map[string]interface{}{
+		calendar.CalendarColumns.UpdatedBy: c.UpdatedBy,
+	}
+	if c.Name != "" {
+		values[calendar.CalendarColumns.Name] = c.Name
+	}
+	if c.Timezone != "" {
+		values[calendar.CalendarColumns.Timezone] = c.Timezone
+	}
+	if c.Exclusions.Dates != nil || c.Exclusions.Ranges != nil {
+		values[calendar.CalendarColumns.Exclusions] = c.Exclusions
+	}
+	if err := calendar.UpdatesFromMap(c.ID, values); err != nil {
+		log.WithField("id", c.ID).Errorf("occurred exception when updating calendar: %v", err)
+		return nil, commons.StatusDBOperationAbnormal
+	}
+	return GetCalendar(c.ID)
+}
+
+// ImportCalendar merges the events of iCalendar data into the calendar, the
+// all-day events exclude their dates and the others exclude their time
+// ranges. The floating times and dates are in the timezone of calendar, the
+// recurring events are expanded within importYears, and the dates and ranges
+// which have been excluded are not added again.
+func ImportCalendar(id uint64, data []byte, updatedBy string) (*ImportResult, *commons.Error) {
+	if len(updatedBy) > maxOperatorLength {
+		return nil, &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("updated_by is at most %d characters", maxOperatorLength)}
+	}
+	c, ce := GetCalendar(id)
+	if ce != nil {
+		return nil, ce
+	}
+	loc, err := c.Location()
+	if err != nil {
+		return nil, &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("timezone %q of calendar invalid: %v", c.Timezone, err)}
+	}
+	now := time.Now()
+	events, ignored, err := utils.ParseICS(data, loc, now, now.AddDate(importYears, 0, 0))
+	if err != nil {
+		return nil, &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("iCalendar invalid: %v", err)}
+	}
+
+	dates := make(map[string]bool, len(c.Exclusions.Dates))
+	for _, d := range c.Exclusions.Dates {
+		dates[d.Date] = true
+	}
+	ranges := make(map[calendar.Range]bool, len(c.Exclusions.Ranges))
+	for _, r := range c.Exclusions.Ranges {
+		ranges[r] = true
+	}
+	for _, e := range events {
+		if !e.AllDay {
+			r := calendar.Range{
+				Start:   uint64(e.Start.UnixNano()),
+				End:     uint64(e.End.UnixNano()),
+				Summary: e.Summary,
+			}
+			if !ranges[r] {
+				ranges[r] = true
+				c.Exclusions.Ranges = append(c.Exclusions.Ranges, r)
+			}
+			continue
+		}
+		for day := e.Start; day.Before(e.End); day = day.AddDate(0, 0, 1) {
+			if date := day.Format(calendar.DateLayout); !dates[date] {
+				dates[date] = true
+				c.Exclusions.Dates = append(c.Exclusions.Dates, calendar.Date{Date: date, Summary: e.Summary})
+			}
+		}
+	}
+
+	values := map[string]interface{}{
+		calendar.CalendarColumns.Exclusions: c.Exclusions,
+		calendar.CalendarColumns.UpdatedBy:  updatedBy,
+	}
+	if err := calendar.UpdatesFromMap(id, values); err != nil {
+		log.WithField("id", id).Errorf("occurred exception when importing calendar: %v", err)
+		return nil, commons.StatusDBOperationAbnormal
+	}
+	c, ce = GetCalendar(id)
+	if ce != nil {
+		return nil, ce
+	}
+	return &ImportResult{Calendar: c, Imported: len(events), Ignored: ignored}, nil
+}
+
+// GetCalendar returns the calendar which is not deleted.
+func GetCalendar(id uint64) (*calendar.Calendar, *commons.Error) {
+	c, err := calendar.Get(id)
+	if err != nil {
+		log.WithField("id", id).Errorf("occurred exception when getting calendar: %v", err)
+		return nil, commons.StatusDBOperationAbnormal
+	}
+	if c == nil || c.DeletedAt > 0 {
+		return nil, &commons.Error{
+			Code:  http.StatusNotFound,
+			Error: fmt.Errorf("Not found calendar %d", id)}
+	}
+	return c, nil
+}
+
+// DeleteCalendar deletes the calendar softly, it's refused while any task
+// references it.
+func DeleteCalendar(id uint64) *commons.Error {
+	if _, ce := GetCalendar(id); ce != nil {
+		return ce
+	}
+	count, err := task.CountByCalendar(id)
+	if err != nil {
+		log.WithField("id", id).Errorf("occurred exception when counting tasks of calendar: %v", err)
+		return commons.StatusDBOperationAbnormal
+	}
+	if count > 0 {
+		return &commons.Error{
+			Code:  http.StatusConflict,
+			Error: fmt.Errorf("calendar %d is referenced by %d tasks", id, count)}
+	}
+	if err := calendar.DeleteAt(id); err != nil {
+		log.WithField("id", id).Errorf("occurred exception when deleting calendar: %v", err)
+		return commons.StatusDBOperationAbnormal
+	}
+	return nil
+}
+
+// PreviewTask returns the next n fire times of the cron of task, the ones
+// excluded by its calendar are included as skipped.
+func PreviewTask(id uint64, n int) ([]middleware.Slot, *commons.Error) {
+	if n == 0 {
+		n = defaultPreviewSlots
+	}
+	if n < 0 || n > maxPreviewSlots {
+		return nil, &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("n %d should be between 1 and %d", n, maxPreviewSlots)}
+	}
+	t, ce := GetTask(id, false)
+	if ce != nil {
+		return nil, ce
+	}
+	if t.Cron == "" {
+		return nil, &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("task %d has no cron", id)}
+	}
+	slots, err := middleware.Preview(t, firesFrom(t), n)
+	if err != nil {
+		return nil, &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("task %d can't be previewed: %v", id, err)}
+	}
+	return slots, nil
+}
+
+// validateCalendar checks the operators, timezone and exclusions of calendar.
+func validateCalendar(c *calendar.Calendar) *commons.Error {
+	if len(c.Name) > maxCalendarNameLength || len(c.CreatedBy) > maxOperatorLength || len(c.UpdatedBy) > maxOperatorLength {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("name is at most %d, created_by and updated_by are at most %d characters", maxCalendarNameLength, maxOperatorLength)}
+	}
+	if _, err := c.Location(); err != nil || c.Timezone == "Local" {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("timezone %q invalid, should be an IANA name, e.g. Asia/Shanghai", c.Timezone)}
+	}
+	for _, d := range c.Exclusions.Dates {
+		if _, err := time.Parse(calendar.DateLayout, d.Date); err != nil {
+			return &commons.Error{
+				Code:  http.StatusBadRequest,
+				Error: fmt.Errorf("date %q invalid, should be like %s", d.Date, calendar.DateLayout)}
+		}
+	}
+	for _, r := range c.Exclusions.Ranges {
+		if r.Start >= r.End {
+			return &commons.Error{
+				Code:  http.StatusBadRequest,
+				Error: fmt.Errorf("range [%d, %d) invalid, start should be before end", r.Start, r.End)}
+		}
+	}
+	return nil
+}
+
+// validateTaskCalendar checks the calendar which the task references exists.
+func validateTaskCalendar(t *task.Task) *commons.Error {
+	if t.CalendarID == nil {
+		return nil
+	}
+	if _, err := calendar.GetExcludeDeleted(*t.CalendarID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &commons.Error{
+				Code:  http.StatusBadRequest,
+				Error: fmt.Errorf("calendar %d not found", *t.CalendarID)}
+		}
+		log.WithField("calendar", *t.CalendarID).Errorf("occurred exception when getting calendar: %v", err)
+		return commons.StatusDBOperationAbnormal
+	}
+	return nil
+}
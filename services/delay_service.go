@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/galaxy-center/galaxy/commons"
+	"github.com/galaxy-center/galaxy/middleware"
+	"github.com/galaxy-center/galaxy/models/task"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/datatypes"
+)
+
+const (
+	// maxDedupeKeyLength fits the code of task, which is varchar(64).
+	maxDedupeKeyLength = 64 - len(task.DelayCodePrefix)
+	// delayTaskName the name of delay tasks.
+	delayTaskName = "delay"
+	// delayBatchSize the max number of delay tasks in one insert.
+	delayBatchSize = 200
+	// delayBatchWait how long a batch waits for more delay tasks.
+	delayBatchWait = 10 * time.Millisecond
+)
+
+var (
+	// batcher inserts the delay tasks by batch, it's nil until
+	// StartDelayBatcher.
+	batcher atomic.Value
+
+	// errDuplicatedDelay the dedupe key has been taken in the same batch.
+	errDuplicatedDelay = errors.New("duplicated dedupe key")
+	// errBatcherStopped the delay task is not inserted since the node is
+	// stopping.
+	errBatcherStopped = errors.New("delay batcher has been stopped")
+)
+
+// DelayMessage a one-shot message delivered by executor after the delay.
+type DelayMessage struct {
+	// Executor delivers the message, e.g. HTTP, KAFKA.
+	Executor task.Executor `json:"executor"`
+	// Payload the TaskConfig#Content of executor.
+	Payload datatypes.JSON `json:"payload"`
+	// Headers the TaskConfig#Headers of executor.
+	Headers datatypes.JSON `json:"headers,omitempty"`
+	// Delay unit is seconds, exclusive with FireAt.
+	Delay int64 `json:"delay,omitempty"`
+	// FireAt the unix nano time to deliver, exclusive with Delay.
+	FireAt uint64 `json:"fire_at,omitempty"`
+	// DedupeKey the message is created only once for the same key.
+	DedupeKey string `json:"dedupe_key,omitempty"`
+	// Timeout unit is seconds.
+	Timeout   int    `json:"timeout,omitempty"`
+	CreatedBy string `json:"created_by,omitempty"`
+}
+
+// DelayReceipt the result of creating a delay message.
+type DelayReceipt struct {
+	TaskID uint64 `json:"task_id"`
+	Code   string `json:"code"`
+	FireAt uint64 `json:"fire_at"`
+	// Duplicated the message had been created with the same dedupe key,
+	// TaskID is the existing one.
+	Duplicated bool `json:"duplicated"`
+}
+
+// delayRequest a delay task waiting for the batch insert.
+type delayRequest struct {
+	task *task.Task
+	done chan error
+}
+
+// delayBatcher the queue of delay tasks waiting to be inserted by batch.
+type delayBatcher struct {
+	queue chan *delayRequest
+	// stopped is closed once the batcher stops, the tasks left in queue
+	// will never be inserted.
+	stopped chan struct{}
+}
+
+// insert waits until the task is inserted by batch, fails if the batcher
+// stops before that.
+func (b *delayBatcher) insert(t *task.Task) error {
+	req := &delayRequest{task: t, done: make(chan error, 1)}
+	select {
+	case b.queue <- req:
+	case <-b.stopped:
+		return errBatcherStopped
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-b.stopped:
+		// the last batch is done before stopped.
+		select {
+		case err := <-req.done:
+			return err
+		default:
+			return errBatcherStopped
+		}
+	}
+}
+
+// StartDelayBatcher inserts the delay tasks by batch until ctx is done, they
+// are inserted one by one before it's started.
+func StartDelayBatcher(ctx context.Context) {
+	b := &delayBatcher{
+		queue:   make(chan *delayRequest, delayBatchSize*4),
+		stopped: make(chan struct{}),
+	}
+	batcher.Store(b)
+
+	go func(ctx context.Context) {
+		for {
+			select {
+			case <-ctx.Done():
+				close(b.stopped)
+				return
+
+			case req := <-b.queue:
+				batch := []*delayRequest{req}
+				timer := time.NewTimer(delayBatchWait)
+			collect:
+				for len(batch) < delayBatchSize {
+					select {
+					case req := <-b.queue:
+						batch = append(batch, req)
+					case <-timer.C:
+						break collect
+					}
+				}
+				timer.Stop()
+				insertDelays(batch)
+			}
+		}
+	}(ctx)
+}
+
+// CreateDelay creates a one-shot task with its config from the message, it
+// will be deleted softly once delivered. It's refused once the batcher stops.
+func CreateDelay(m *DelayMessage) (*DelayReceipt, *commons.Error) {
+	t, ce := buildDelayTask(m)
+	if ce != nil {
+		return nil, ce
+	}
+	if existing, ce := getDelayByCode(t.Code); ce != nil || existing != nil {
+		return existing, ce
+	}
+
+	var err error
+	if b, ok := batcher.Load().(*delayBatcher); ok {
+		err = b.insert(t)
+	} else {
+		err = task.CreateWithConfig(t)
+	}
+	if err == errBatcherStopped {
+		return nil, &commons.Error{Code: http.StatusServiceUnavailable, Error: err}
+	}
+	if task.IsDuplicated(err) || err == errDuplicatedDelay {
+		if existing, ce := getDelayByCode(t.Code); ce != nil || existing != nil {
+			return existing, ce
+		}
+	}
+	if err != nil {
+		log.WithField("code", t.Code).Errorf("occurred exception when inserting delay task: %v", err)
+		return nil, commons.StatusDBOperationAbnormal
+	}
+
+	middleware.Schedule(t)
+	return &DelayReceipt{TaskID: t.ID, Code: t.Code, FireAt: t.ExpiredAt}, nil
+}
+
+// buildDelayTask returns the ENABLED DelayQueue task of message.
+func buildDelayTask(m *DelayMessage) (*task.Task, *commons.Error) {
+	if ce := validateExecutor(m.Executor); ce != nil {
+		return nil, ce
+	}
+	if len(m.Payload) == 0 {
+		return nil, &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("payload is required")}
+	}
+	if (m.Delay > 0) == (m.FireAt > 0) || m.Delay < 0 {
+		return nil, &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("either positive delay or fire_at is required")}
+	}
+	if len(m.DedupeKey) > maxDedupeKeyLength || len(m.CreatedBy) > maxOperatorLength {
+		return nil, &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("dedupe_key is at most %d and created_by is at most %d characters", maxDedupeKeyLength, maxOperatorLength)}
+	}
+	if m.Timeout < 0 {
+		return nil, &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("timeout should not be negative")}
+	}
+
+	fireAt := m.FireAt
+	if m.Delay > 0 {
+		fireAt = uint64(time.Now().Add(time.Duration(m.Delay) * time.Second).UnixNano())
+	}
+	key := m.DedupeKey
+	if key == "" {
+		key = uuid.NewV4().String()
+	}
+	return &task.Task{
+		Name:               delayTaskName,
+		Code:               task.DelayCodePrefix + key,
+		Type:               task.DelayQueue,
+		Status:             task.ENABLED,
+		ExpiredAt:          fireAt,
+		Timeout:            m.Timeout,
+		SchedulingCategory: task.SINGLETON,
+		Executor:           m.Executor,
+		CreatedBy:          m.CreatedBy,
+		UpdatedBy:          m.CreatedBy,
+		TaskConfig: &taskconfig.TaskConfig{
+			Headers:   m.Headers,
+			Content:   m.Payload,
+			CreatedBy: m.CreatedBy,
+			UpdatedBy: m.CreatedBy,
+		},
+	}, nil
+}
+
+// getDelayByCode returns the receipt of existing delay task.
+func getDelayByCode(code string) (*DelayReceipt, *commons.Error) {
+	t, err := task.GetByCode(code)
+	if err != nil {
+		log.WithField("code", code).Errorf("occurred exception when getting delay task: %v", err)
+		return nil, commons.StatusDBOperationAbnormal
+	}
+	if t == nil {
+		return nil, nil
+	}
+	return &DelayReceipt{TaskID: t.ID, Code: t.Code, FireAt: t.ExpiredAt, Duplicated: true}, nil
+}
+
+// insertDelays inserts the batch by one transaction, falls back to insert
+// one by one if it fails, e.g. some codes have been taken meanwhile.
+func insertDelays(batch []*delayRequest) {
+	// the same dedupe key in one batch is inserted once, others are reported
+	// as duplicated after then.
+	seen := make(map[string]bool, len(batch))
+	unique := make([]*delayRequest, 0, len(batch))
+	tasks := make([]*task.Task, 0, len(batch))
+	var duplicated []*delayRequest
+	defer func() {
+		for _, req := range duplicated {
+			req.done <- errDuplicatedDelay
+		}
+	}()
+	for _, req := range batch {
+		if seen[req.task.Code] {
+			duplicated = append(duplicated, req)
+			continue
+		}
+		seen[req.task.Code] = true
+		unique = append(unique, req)
+		tasks = append(tasks, req.task)
+	}
+
+	err := task.CreateBatchWithConfig(tasks)
+	if err == nil {
+		for _, req := range unique {
+			req.done <- nil
+		}
+		return
+	}
+	log.Warnf("batch insert %d delay tasks failed, fall back to insert one by one: %v", len(tasks), err)
+	for _, req := range unique {
+		// clears the ids assigned by the rolled back transaction.
+		req.task.ID = 0
+		req.task.TaskConfigID = nil
+		req.task.TaskConfig.ID = 0
+		req.done <- task.CreateWithConfig(req.task)
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/galaxy-center/galaxy/commons"
+	"github.com/galaxy-center/galaxy/models"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+)
+
+// CreateSchedulingRecord returns status.
+func CreateSchedulingRecord(r *schedulingrecord.SchedulingRecord) *commons.Error {
+	t, err := task.Get(r.TaskID)
+	if err != nil {
+		log.WithField("id", r.TaskID).Error("occurred exception when getting task")
+		return commons.StatusDBOperationAbnormal
+	}
+	if t == nil {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("task %d of record not found", r.TaskID)}
+	}
+	if r.Status == "" {
+		r.Status = schedulingrecord.NEW
+	}
+	if r.Status != schedulingrecord.NEW {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("status of new record should be %s", schedulingrecord.NEW)}
+	}
+	if err := schedulingrecord.Create(r); err != nil {
+		log.WithField("record", r).Errorf("occurred exception when inserting scheduling record: %v", err)
+		return commons.StatusDBOperationAbnormal
+	}
+	return nil
+}
+
+// UpdateSchedulingRecord returns error, the status is moved by the lifecycle
+// of record, returns 409 if the transition is illegal or lost.
+func UpdateSchedulingRecord(r *schedulingrecord.SchedulingRecord) *commons.Error {
+	status := r.Status
+	if status != "" {
+		current, ce := GetSchedulingRecord(r.ID)
+		if ce != nil {
+			return ce
+		}
+		if current.Status != r.Status {
+			if err := schedulingrecord.Transition(r.ID, current.Status, r.Status, r.Message); err != nil {
+				var te *schedulingrecord.TransitionError
+				if errors.As(err, &te) {
+					return &commons.Error{Code: http.StatusConflict, Error: err}
+				}
+				log.WithField("record", r).Errorf("occurred exception when moving scheduling record: %v", err)
+				return commons.StatusDBOperationAbnormal
+			}
+		}
+		// has been moved, others are updated as usual.
+		r.Status = ""
+	}
+	if err := schedulingrecord.Updates(r); err != nil {
+		log.WithField("record", r).Errorf("occurred exception when updating scheduling record: %v", err)
+		return commons.StatusDBOperationAbnormal
+	}
+	r.Status = status
+	return nil
+}
+
+// UpsertSchedulingRecord create or update.
+func UpsertSchedulingRecord(r *schedulingrecord.SchedulingRecord) *commons.Error {
+	if r.ID == uint64(0) {
+		return CreateSchedulingRecord(r)
+	}
+	return UpdateSchedulingRecord(r)
+}
+
+// DeleteSchedulingRecord delete by os storage.
+func DeleteSchedulingRecord(id uint64, deletedAt bool) (bool, *commons.Error) {
+	if deletedAt {
+		if err := schedulingrecord.DeleteAt(id); err != nil {
+			log.Errorf("occurred exception when deleting scheduling record: %d", id)
+			return false, commons.StatusDBOperationAbnormal
+		}
+		return true, nil
+	}
+	if err := schedulingrecord.Delete(id); err != nil {
+		log.Errorf("occurred exception when deleting scheduling record: %d", id)
+		return false, commons.StatusDBOperationAbnormal
+	}
+	return true, nil
+}
+
+// GetSchedulingRecord returns target or status.
+func GetSchedulingRecord(id uint64) (*schedulingrecord.SchedulingRecord, *commons.Error) {
+	r, err := schedulingrecord.Get(id)
+	if err != nil {
+		log.WithField("id", id).Error("occurred exception when getting scheduling record")
+		return nil, commons.StatusDBOperationAbnormal
+	}
+	if r == nil {
+		return nil, &commons.Error{
+			Code:  http.StatusNotFound,
+			Error: fmt.Errorf("Not found %d", id)}
+	}
+	return r, nil
+}
+
+// GetSchedulingRecordsWith pagination queries.
+func GetSchedulingRecordsWith(p *models.Pagination) (*models.Response, *commons.Error) {
+	res, err := schedulingrecord.PaginateQuery(p)
+	if err != nil {
+		log.WithField("pagination", p).Error("occurred exception when getting scheduling records")
+		return nil, commons.StatusDBOperationAbnormal
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/galaxy-center/galaxy/commons"
+	"github.com/galaxy-center/galaxy/models"
+	taskconfig "github.com/galaxy-center/galaxy/models/task_config"
+)
+
+// CreateTaskConfig returns status.
+func CreateTaskConfig(tc *taskconfig.TaskConfig) *commons.Error {
+	if err := taskconfig.Create(tc); err != nil {
+		log.WithField("config", tc).Errorf("occurred exception when inserting task config: %v", err)
+		return commons.StatusDBOperationAbnormal
+	}
+	return nil
+}
+
+// UpdateTaskConfig returns error.
+func UpdateTaskConfig(tc *taskconfig.TaskConfig) *commons.Error {
+	if err := taskconfig.Updates(tc); err != nil {
+		log.WithField("config", tc).Errorf("occurred exception when updating task config: %v", err)
+		return commons.StatusDBOperationAbnormal
+	}
+	return nil
+}
+
+// UpsertTaskConfig create or update.
+func UpsertTaskConfig(tc *taskconfig.TaskConfig) *commons.Error {
+	if tc.ID == uint64(0) {
+		return CreateTaskConfig(tc)
+	}
+	return UpdateTaskConfig(tc)
+}
+
+// DeleteTaskConfig delete by os storage.
+func DeleteTaskConfig(id uint64, deletedAt bool) (bool, *commons.Error) {
+	if deletedAt {
+		if err := taskconfig.DeleteAt(id); err != nil {
+			log.Errorf("occurred exception when deleting task config: %d", id)
+			return false, commons.StatusDBOperationAbnormal
+		}
+		return true, nil
+	}
+	if err := taskconfig.Delete(id); err != nil {
+		log.Errorf("occurred exception when deleting task config: %d", id)
+		return false, commons.StatusDBOperationAbnormal
+	}
+	return true, nil
+}
+
+// GetTaskConfig returns target or status.
+func GetTaskConfig(id uint64) (*taskconfig.TaskConfig, *commons.Error) {
+	tc, err := taskconfig.Get(id)
+	if err != nil {
+		log.WithField("id", id).Error("occurred exception when getting task config")
+		return nil, commons.StatusDBOperationAbnormal
+	}
+	if tc == nil {
+		return nil, &commons.Error{
+			Code:  http.StatusNotFound,
+			Error: fmt.Errorf("Not found %d", id)}
+	}
+	return tc, nil
+}
+
+// GetTaskConfigsWith pagination queries.
+func GetTaskConfigsWith(p *models.Pagination) (*models.Response, *commons.Error) {
+	res, err := taskconfig.PaginateQuery(p)
+	if err != nil {
+		log.WithField("pagination", p).Error("occurred exception when getting task configs")
+		return nil, commons.StatusDBOperationAbnormal
+	}
+
+	return &res, nil
+}
@@ -1,29 +1,138 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
+	"time"
 
 	"github.com/galaxy-center/galaxy/commons"
+	"github.com/galaxy-center/galaxy/config"
+	"github.com/galaxy-center/galaxy/cron"
+	"github.com/galaxy-center/galaxy/executor"
+	"github.com/galaxy-center/galaxy/middleware"
 	"github.com/galaxy-center/galaxy/models"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
 	"github.com/galaxy-center/galaxy/models/task"
+	"github.com/galaxy-center/galaxy/models/workflow"
+	"gorm.io/datatypes"
 )
 
 // CreateTask returns status.
 func CreateTask(t *task.Task) *commons.Error {
-	if err := task.Create(t); err != nil {
+	ignoreScheduled(t)
+	if t.Type == task.DelayJob && t.Cron == "" {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("cron is required by %s", task.DelayJob)}
+	}
+	if ce := validateExecutor(t.Executor); ce != nil {
+		return ce
+	}
+	if ce := validateWorkflow(&task.Task{}, t); ce != nil {
+		return ce
+	}
+	if ce := validateRunLimits(t); ce != nil {
+		return ce
+	}
+	if ce := validateTaskCalendar(t); ce != nil {
+		return ce
+	}
+	if ce := validateCron(t); ce != nil {
+		return ce
+	}
+	if ce := validateRetryPolicy(t.RetryPolicy); ce != nil {
+		return ce
+	}
+	if ce := validateMisfirePolicy(t.MisfirePolicy); ce != nil {
+		return ce
+	}
+	if ce := validateConcurrency(t); ce != nil {
+		return ce
+	}
+	if t.Status == "" {
+		t.Status = task.PENDING
+	}
+	if t.Status != task.PENDING && t.Status != task.ENABLED {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("status of new task should be %s or %s", task.PENDING, task.ENABLED)}
+	}
+	if err := task.CreateWithConfig(t); err != nil {
 		log.WithField("task", t).Errorf("occurred exception when inserting task: %v", err)
 		return commons.StatusDBOperationAbnormal
 	}
+	middleware.Schedule(t)
 	return nil
 }
 
-// UpdateTask returns error.
+// UpdateTask updates the non-zero fields of task, they are validated along
+// with the stored ones. The fields set by scheduler are ignored, and the
+// status is moved as EnableTask, DisableTask and so on.
 func UpdateTask(t *task.Task) *commons.Error {
-	if err := task.Updates(t); err != nil {
+	current, ce := GetTask(t.ID, false)
+	if ce != nil {
+		return ce
+	}
+	ignoreScheduled(t)
+	merged := mergeTask(current, t)
+	if t.Executor != "" {
+		if ce := validateExecutor(t.Executor); ce != nil {
+			return ce
+		}
+	}
+	if ce := validateWorkflow(current, t); ce != nil {
+		return ce
+	}
+	if ce := validateRunLimits(merged); ce != nil {
+		return ce
+	}
+	if ce := validateTaskCalendar(t); ce != nil {
+		return ce
+	}
+	if t.ExpiredAt == 0 && rescheduled(current, t) {
+		// the next fire time follows the changes.
+		merged.ExpiredAt = 0
+	}
+	if ce := validateCron(merged); ce != nil {
+		return ce
+	}
+	if merged.ExpiredAt != current.ExpiredAt {
+		t.ExpiredAt = merged.ExpiredAt
+	}
+	if ce := validateRetryPolicy(merged.RetryPolicy); ce != nil {
+		return ce
+	}
+	if ce := validateMisfirePolicy(merged.MisfirePolicy); ce != nil {
+		return ce
+	}
+	if ce := validateConcurrency(merged); ce != nil {
+		return ce
+	}
+	// the status is moved after the others, as the actions of lifecycle.
+	to := t.Status
+	t.Status = ""
+	if to != "" && to != current.Status && !task.CanTransit(current.Status, to) {
+		return &commons.Error{
+			Code:  http.StatusConflict,
+			Error: fmt.Errorf("task %d is %s, can't be moved to %s", t.ID, current.Status, to)}
+	}
+	err := task.Updates(t)
+	t.Status = to
+	if err != nil {
 		log.WithField("task", t).Errorf("occurred exception when updating task: %v", err)
 		return commons.StatusDBOperationAbnormal
 	}
+	if to != "" && to != current.Status {
+		_, ce := transitTask(t.ID, to, current.Status)
+		return ce
+	}
+	merged.Status = current.Status
+	if merged.ExpiredAt != current.ExpiredAt {
+		middleware.Schedule(merged)
+	}
 	return nil
 }
 
@@ -37,6 +146,7 @@ func UpsertTask(t *task.Task) *commons.Error {
 
 // DeleteTask delete by os storage.
 func DeleteTask(id uint64, deletedAt bool) (bool, *commons.Error) {
+	middleware.Unschedule(id)
 	if deletedAt {
 		if err := task.DeleteAt(id); err != nil {
 			log.Errorf("occurred exception when deleting task: %d", id)
@@ -51,9 +161,14 @@ func DeleteTask(id uint64, deletedAt bool) (bool, *commons.Error) {
 	return true, nil
 }
 
-// GetTask returns target or status.
-func GetTask(id uint64) (*task.Task, *commons.Error) {
-	t, err := task.Get(id)
+// GetTask returns target or status, the config of target will be embedded
+// if withConfig is true.
+func GetTask(id uint64, withConfig bool) (*task.Task, *commons.Error) {
+	get := task.Get
+	if withConfig {
+		get = task.GetWithConfig
+	}
+	t, err := get(id)
 	if err != nil {
 		log.WithField("id", id).Error("occurred exception when getting task")
 		return nil, commons.StatusDBOperationAbnormal
@@ -76,3 +191,339 @@ func GetTasksWith(p *models.Pagination) (*models.Response, *commons.Error) {
 
 	return &res, nil
 }
+
+// maxCronLength fits the cron of task, which is varchar(255).
+const maxCronLength = 255
+
+// validateCron checks the cron expression of task, and fills the next fire
+// time if absent, e.g. creating a DelayJob or changing the cron.
+func validateCron(t *task.Task) *commons.Error {
+	if _, err := t.Location(); err != nil || t.Timezone == "Local" {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("timezone %q invalid, should be an IANA name, e.g. Asia/Shanghai", t.Timezone)}
+	}
+	if t.Cron == "" {
+		return nil
+	}
+	if len(t.Cron) > maxCronLength {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("cron is at most %d characters", maxCronLength)}
+	}
+	if _, err := cron.Parse(t.Cron); err != nil {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("cron %q invalid: %v", t.Cron, err)}
+	}
+	next, err := middleware.NextFire(t, firesFrom(t))
+	if err != nil {
+		log.WithField("task", t.ID).Errorf("occurred exception when computing next fire time: %v", err)
+		return commons.StatusDBOperationAbnormal
+	}
+	if next.IsZero() || (t.EndAt > 0 && next.UnixNano() > int64(t.EndAt)) {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("cron %q never fires between start_at and end_at, except the slots excluded by calendar", t.Cron)}
+	}
+	if t.Type != task.DelayQueue && t.ExpiredAt == 0 {
+		t.ExpiredAt = uint64(next.UnixNano())
+	}
+	return nil
+}
+
+// firesFrom returns the time after which the cron of task fires, it's the
+// start_at of task if it's in the future.
+func firesFrom(t *task.Task) time.Time {
+	now := time.Now()
+	if startAt := time.Unix(0, int64(t.StartAt)); t.StartAt > 0 && startAt.After(now) {
+		// fires at start_at if it's matched.
+		return startAt.Add(-time.Nanosecond)
+	}
+	return now
+}
+
+// validateRunLimits checks the start_at, end_at and max_runs of task, the
+// runs are counted by scheduler.
+func validateRunLimits(t *task.Task) *commons.Error {
+	if t.MaxRuns < 0 {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("max runs %d should not be negative", t.MaxRuns)}
+	}
+	if t.EndAt > 0 && t.StartAt > t.EndAt {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("start_at %d should not be after end_at %d", t.StartAt, t.EndAt)}
+	}
+	return nil
+}
+
+// rescheduled returns true if the changes move the fire times of the stored
+// task, e.g. another cron, timezone or calendar.
+func rescheduled(current, changes *task.Task) bool {
+	calendarChanged := changes.CalendarID != nil &&
+		(current.CalendarID == nil || *changes.CalendarID != *current.CalendarID)
+	return calendarChanged ||
+		(changes.Cron != "" && changes.Cron != current.Cron) ||
+		(changes.Timezone != "" && changes.Timezone != current.Timezone) ||
+		(changes.StartAt > 0 && changes.StartAt != current.StartAt)
+}
+
+// ignoreScheduled clears the fields of task which are set by scheduler, so
+// that the task got from the API can be posted back.
+func ignoreScheduled(t *task.Task) {
+	t.Runs, t.DisabledReason, t.FiredAt = 0, "", 0
+}
+
+// mergeTask returns the stored task with the non-zero fields of changes, as
+// task.Updates saves them.
+func mergeTask(current, changes *task.Task) *task.Task {
+	merged := *current
+	mergeFields(reflect.ValueOf(&merged).Elem(), reflect.ValueOf(changes).Elem())
+	return &merged
+}
+
+// mergeFields sets the non-zero fields of src to dst, the ones of embedded
+// structs are merged one by one.
+func mergeFields(dst, src reflect.Value) {
+	for i := 0; i < src.NumField(); i++ {
+		f := src.Field(i)
+		if f.Kind() == reflect.Struct {
+			mergeFields(dst.Field(i), f)
+			continue
+		}
+		if !f.IsZero() {
+			dst.Field(i).Set(f)
+		}
+	}
+}
+
+// EnableTask moves the PENDING or DISABLED task to ENABLED.
+func EnableTask(id uint64) (*task.Task, *commons.Error) {
+	return transitTask(id, task.ENABLED, task.PENDING, task.DISABLED)
+}
+
+// DisableTask moves the task to DISABLED, and removes it from the time wheel.
+func DisableTask(id uint64) (*task.Task, *commons.Error) {
+	return transitTask(id, task.DISABLED, task.PENDING, task.ENABLED, task.PAUSED)
+}
+
+// PauseTask moves the ENABLED task to PAUSED, and removes it from the time wheel.
+func PauseTask(id uint64) (*task.Task, *commons.Error) {
+	return transitTask(id, task.PAUSED, task.ENABLED)
+}
+
+// ResumeTask moves the PAUSED task to ENABLED.
+func ResumeTask(id uint64) (*task.Task, *commons.Error) {
+	return transitTask(id, task.ENABLED, task.PAUSED)
+}
+
+// transitTask moves the task in one of from to another status, the next fire
+// time will be recomputed from cron while it's going to be ENABLED.
+func transitTask(id uint64, to task.Status, from ...task.Status) (*task.Task, *commons.Error) {
+	t, ce := GetTask(id, false)
+	if ce != nil {
+		return nil, ce
+	}
+	allowed := false
+	for _, f := range from {
+		if t.Status == f {
+			allowed = true
+		}
+	}
+	if !allowed || t.DeletedAt > 0 {
+		return nil, &commons.Error{
+			Code:  http.StatusConflict,
+			Error: fmt.Errorf("task %d is %s, can't be moved to %s", id, t.Status, to)}
+	}
+
+	values := make(map[string]interface{}, 1)
+	if to == task.ENABLED && t.Cron != "" {
+		next, err := middleware.NextFire(t, firesFrom(t))
+		if err != nil {
+			return nil, &commons.Error{
+				Code:  http.StatusBadRequest,
+				Error: fmt.Errorf("cron %q, timezone %q or calendar invalid: %v", t.Cron, t.Timezone, err)}
+		}
+		exhausted := t.MaxRuns > 0 && t.Runs >= t.MaxRuns
+		if next.IsZero() || exhausted || (t.EndAt > 0 && next.UnixNano() > int64(t.EndAt)) {
+			return nil, &commons.Error{
+				Code:  http.StatusConflict,
+				Error: fmt.Errorf("task %d is exhausted by end_at or max_runs, fired %d runs", id, t.Runs)}
+		}
+		t.ExpiredAt = uint64(next.UnixNano())
+		values[task.TaskColumns.ExpiredAt] = t.ExpiredAt
+	}
+	if to == task.ENABLED {
+		t.DisabledReason = ""
+		values[task.TaskColumns.DisabledReason] = t.DisabledReason
+	}
+	if err := task.Transition(id, t.Status, to, values); err != nil {
+		var te *task.TransitionError
+		if errors.As(err, &te) {
+			return nil, &commons.Error{Code: http.StatusConflict, Error: err}
+		}
+		log.WithField("id", id).Errorf("occurred exception when moving task to %s: %v", to, err)
+		return nil, commons.StatusDBOperationAbnormal
+	}
+	t.Status = to
+
+	if to == task.ENABLED {
+		middleware.Schedule(t)
+	} else {
+		middleware.Unschedule(id)
+	}
+	return t, nil
+}
+
+// maxOperatorLength the max length of created_by of scheduling record.
+const maxOperatorLength = 32
+
+// TriggerTask runs the task immediately out of its schedule, the overrides
+// are merged into the content of task config for this run only.
+func TriggerTask(id uint64, createdBy string, overrides datatypes.JSON) (*schedulingrecord.SchedulingRecord, *commons.Error) {
+	if createdBy == "" || len(createdBy) > maxOperatorLength {
+		return nil, &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("created_by is required and at most %d characters", maxOperatorLength)}
+	}
+	if len(overrides) > 0 {
+		var content map[string]interface{}
+		if err := json.Unmarshal(overrides, &content); err != nil {
+			return nil, &commons.Error{
+				Code:  http.StatusBadRequest,
+				Error: fmt.Errorf("overrides should be a JSON object: %v", err)}
+		}
+	}
+	t, ce := GetTask(id, false)
+	if ce != nil {
+		return nil, ce
+	}
+	if t.DeletedAt > 0 {
+		return nil, &commons.Error{
+			Code:  http.StatusNotFound,
+			Error: fmt.Errorf("task %d has been deleted", id)}
+	}
+
+	r, err := middleware.Trigger(t, createdBy, overrides)
+	if errors.Is(err, middleware.ErrNotStarted) {
+		return nil, &commons.Error{Code: http.StatusServiceUnavailable, Error: err}
+	}
+	if err != nil {
+		log.WithField("task", id).Errorf("occurred exception when triggering task: %v", err)
+		return nil, commons.StatusDBOperationAbnormal
+	}
+	return r, nil
+}
+
+// validateExecutor checks the executor has been registered.
+func validateExecutor(e task.Executor) *commons.Error {
+	if _, ok := executor.Lookup(e); !ok {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("executor %q is unknown, should be one of %v", e, executor.Names())}
+	}
+	if e == task.COMMAND && config.Global().Executor.DisableCommand {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("executor %s is disabled", e)}
+	}
+	if e == task.KAFKA && !executor.HasProducer() {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("executor %s is unavailable, brokers of kafka are not configured", e)}
+	}
+	return nil
+}
+
+// validateWorkflow rejects changing the workflow of the stored task by the
+// changes, it's done by creating the workflow with its nodes. The unchanged
+// ones pass, so that the task got from the API can be posted back.
+func validateWorkflow(current, changes *task.Task) *commons.Error {
+	workflowChanged := changes.WorkflowID != nil &&
+		(current.WorkflowID == nil || *changes.WorkflowID != *current.WorkflowID)
+	upstreamsChanged := len(changes.Upstreams) > 0 && !reflect.DeepEqual(changes.Upstreams, current.Upstreams)
+	if workflowChanged || upstreamsChanged {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("workflow_id and upstreams are set by creating the workflow")}
+	}
+	if current.WorkflowID == nil || changes.Cron == "" {
+		return nil
+	}
+	// only the owner of workflow is fired by its own schedule.
+	nodes, err := task.GetByWorkflow(*current.WorkflowID)
+	if err != nil {
+		log.WithField("workflow", *current.WorkflowID).Errorf("occurred exception when loading workflow: %v", err)
+		return commons.StatusDBOperationAbnormal
+	}
+	upstreams := make(map[uint64]task.IDs, len(nodes))
+	for _, n := range nodes {
+		upstreams[n.ID] = n.Upstreams
+	}
+	if workflow.Owner(upstreams) != current.ID {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("task %d is fired by workflow %d, cron should be empty", current.ID, *current.WorkflowID)}
+	}
+	return nil
+}
+
+// validateMisfirePolicy checks the misfire policy of task, empty means the
+// default FIRE_ONCE.
+func validateMisfirePolicy(p task.MisfirePolicy) *commons.Error {
+	switch p {
+	case "", task.FIRE_ONCE, task.FIRE_ALL, task.SKIP:
+		return nil
+	}
+	return &commons.Error{
+		Code:  http.StatusBadRequest,
+		Error: fmt.Errorf("misfire policy %q is unknown, should be one of %s, %s, %s", p, task.FIRE_ONCE, task.FIRE_ALL, task.SKIP)}
+}
+
+// validateConcurrency checks the priority, max concurrency and overlap policy
+// of task, empty policy means the default SKIP. The max concurrency limits the
+// runs of task on each node rather than the cluster.
+func validateConcurrency(t *task.Task) *commons.Error {
+	if t.Priority < task.MinPriority || t.Priority > task.MaxPriority {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("priority %d should be from %d to %d", t.Priority, task.MinPriority, task.MaxPriority)}
+	}
+	if t.MaxConcurrency < 0 {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("max concurrency %d should not be negative, it limits the runs of task on each node", t.MaxConcurrency)}
+	}
+	switch t.OverlapPolicy {
+	case "", task.OVERLAP_SKIP, task.OVERLAP_QUEUE, task.OVERLAP_REPLACE:
+		return nil
+	}
+	return &commons.Error{
+		Code:  http.StatusBadRequest,
+		Error: fmt.Errorf("overlap policy %q is unknown, should be one of %s, %s, %s, which act on the runs of each node", t.OverlapPolicy, task.OVERLAP_SKIP, task.OVERLAP_QUEUE, task.OVERLAP_REPLACE)}
+}
+
+// validateRetryPolicy checks the retry policy of task.
+func validateRetryPolicy(p task.RetryPolicy) *commons.Error {
+	var err error
+	switch {
+	case p.MaxAttempts < 0:
+		err = fmt.Errorf("max attempts %d should not be negative", p.MaxAttempts)
+	case p.Backoff != "" && p.Backoff != task.FIXED && p.Backoff != task.EXPONENTIAL:
+		err = fmt.Errorf("backoff %s should be %s or %s", p.Backoff, task.FIXED, task.EXPONENTIAL)
+	case p.Delay < 0 || p.MaxDelay < 0:
+		err = fmt.Errorf("delay %d and max delay %d should not be negative", p.Delay, p.MaxDelay)
+	case p.Jitter < 0 || p.Jitter > 1:
+		err = fmt.Errorf("jitter %v should be in [0, 1]", p.Jitter)
+	default:
+		_, err = p.ParseStatusCodes()
+	}
+	if err != nil {
+		return &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("retry policy invalid: %v", err)}
+	}
+	return nil
+}
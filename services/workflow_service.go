@@ -0,0 +1,233 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/galaxy-center/galaxy/commons"
+	"github.com/galaxy-center/galaxy/middleware"
+	schedulingrecord "github.com/galaxy-center/galaxy/models/scheduling_record"
+	"github.com/galaxy-center/galaxy/models/task"
+	"github.com/galaxy-center/galaxy/models/workflow"
+	"github.com/galaxy-center/galaxy/utils"
+)
+
+const (
+	// maxWorkflowNameLength fits the name of workflow, which is varchar(32).
+	maxWorkflowNameLength = 32
+	// waitingStatus the node has not been started in the run.
+	waitingStatus = "WAITING"
+)
+
+// WorkflowNode a task of workflow with the upstream tasks it depends on.
+type WorkflowNode struct {
+	TaskID    uint64   `json:"task_id"`
+	Name      string   `json:"name,omitempty"`
+	Upstreams task.IDs `json:"upstreams,omitempty"`
+}
+
+// WorkflowRequest the body of creating a workflow.
+type WorkflowRequest struct {
+	Name      string         `json:"name"`
+	CreatedBy string         `json:"created_by"`
+	Nodes     []WorkflowNode `json:"nodes"`
+}
+
+// WorkflowView the workflow with its nodes, every node follows its upstreams.
+type WorkflowView struct {
+	workflow.Workflow
+	Nodes []WorkflowNode `json:"nodes"`
+}
+
+// NodeStatus the status of a node in the workflow run, it's WAITING before
+// the node has been started.
+type NodeStatus struct {
+	WorkflowNode
+	Status   schedulingrecord.Status `json:"status"`
+	RecordID uint64                  `json:"record_id,omitempty"`
+	Attempt  int                     `json:"attempt,omitempty"`
+	Message  string                  `json:"message,omitempty"`
+}
+
+// WorkflowRunView the run of workflow with the status of every node.
+type WorkflowRunView struct {
+	workflow.Run
+	// Status RUNNING until all nodes are over, then FAILED if any of them is
+	// FAILED or TIMEOUT, otherwise FINISHED.
+	Status schedulingrecord.Status `json:"status"`
+	Nodes  []NodeStatus            `json:"nodes"`
+}
+
+// CreateWorkflow creates the workflow of the tasks, a task can only be in one
+// workflow. The owner, which is the first of the roots, begins a run by its
+// own schedule, the other roots are started along with it. The others are
+// fired once all of their upstreams are FINISHED in the same run.
+func CreateWorkflow(req *WorkflowRequest) (*WorkflowView, *commons.Error) {
+	if req.Name == "" || len(req.Name) > maxWorkflowNameLength || len(req.CreatedBy) > maxOperatorLength {
+		return nil, &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("name is required and at most %d, created_by is at most %d characters", maxWorkflowNameLength, maxOperatorLength)}
+	}
+	if len(req.Nodes) == 0 {
+		return nil, &commons.Error{
+			Code:  http.StatusBadRequest,
+			Error: fmt.Errorf("nodes are required")}
+	}
+	graph := make(map[uint64][]uint64, len(req.Nodes))
+	nodes := make(map[uint64]task.IDs, len(req.Nodes))
+	for _, n := range req.Nodes {
+		if _, ok := nodes[n.TaskID]; ok {
+			return nil, &commons.Error{
+				Code:  http.StatusBadRequest,
+				Error: fmt.Errorf("task %d is duplicated", n.TaskID)}
+		}
+		graph[n.TaskID] = n.Upstreams
+		nodes[n.TaskID] = n.Upstreams
+	}
+	if _, err := utils.TopoSort(graph); err != nil {
+		return nil, &commons.Error{Code: http.StatusBadRequest, Error: err}
+	}
+
+	owner := workflow.Owner(nodes)
+	for id, upstreams := range nodes {
+		t, ce := GetTask(id, false)
+		if ce != nil {
+			return nil, ce
+		}
+		var err error
+		switch {
+		case t.DeletedAt > 0:
+			err = fmt.Errorf("task %d has been deleted", id)
+		case t.WorkflowID != nil:
+			return nil, &commons.Error{
+				Code:  http.StatusConflict,
+				Error: fmt.Errorf("task %d is in workflow %d", id, *t.WorkflowID)}
+		case t.SchedulingCategory == task.MULTIPLE:
+			err = fmt.Errorf("task %d is %s, the nodes of workflow should be %s", id, task.MULTIPLE, task.SINGLETON)
+		case len(upstreams) > 0 && t.Cron != "":
+			err = fmt.Errorf("task %d is fired by its upstreams, cron should be empty", id)
+		case id != owner && t.Cron != "":
+			err = fmt.Errorf("task %d is started along with root %d, cron should be empty", id, owner)
+		}
+		if err != nil {
+			return nil, &commons.Error{Code: http.StatusBadRequest, Error: err}
+		}
+	}
+
+	w := &workflow.Workflow{Name: req.Name, CreatedBy: req.CreatedBy, UpdatedBy: req.CreatedBy}
+	if err := workflow.CreateWithNodes(w, nodes); err != nil {
+		if errors.Is(err, workflow.ErrTaskTaken) {
+			return nil, &commons.Error{Code: http.StatusConflict, Error: err}
+		}
+		log.WithField("workflow", req.Name).Errorf("occurred exception when inserting workflow: %v", err)
+		return nil, commons.StatusDBOperationAbnormal
+	}
+	for id := range nodes {
+		if id != owner {
+			middleware.Unschedule(id)
+		}
+	}
+	return GetWorkflow(w.ID)
+}
+
+// GetWorkflow returns the workflow with its nodes.
+func GetWorkflow(id uint64) (*WorkflowView, *commons.Error) {
+	w, err := workflow.Get(id)
+	if err != nil {
+		log.WithField("id", id).Errorf("occurred exception when getting workflow: %v", err)
+		return nil, commons.StatusDBOperationAbnormal
+	}
+	if w == nil || w.DeletedAt > 0 {
+		return nil, &commons.Error{
+			Code:  http.StatusNotFound,
+			Error: fmt.Errorf("Not found workflow %d", id)}
+	}
+	nodes, ce := getWorkflowNodes(id)
+	if ce != nil {
+		return nil, ce
+	}
+	return &WorkflowView{Workflow: *w, Nodes: nodes}, nil
+}
+
+// GetWorkflowRun returns the run of workflow with the status of every node.
+func GetWorkflowRun(id, runID uint64) (*WorkflowRunView, *commons.Error) {
+	run, err := workflow.GetRun(runID)
+	if err != nil {
+		log.WithField("run", runID).Errorf("occurred exception when getting workflow run: %v", err)
+		return nil, commons.StatusDBOperationAbnormal
+	}
+	if run == nil || run.WorkflowID != id {
+		return nil, &commons.Error{
+			Code:  http.StatusNotFound,
+			Error: fmt.Errorf("Not found run %d of workflow %d", runID, id)}
+	}
+	nodes, ce := getWorkflowNodes(id)
+	if ce != nil {
+		return nil, ce
+	}
+	records, err := schedulingrecord.GetByWorkflowRun(runID)
+	if err != nil {
+		log.WithField("run", runID).Errorf("occurred exception when getting records of workflow run: %v", err)
+		return nil, commons.StatusDBOperationAbnormal
+	}
+	latest := make(map[uint64]*schedulingrecord.SchedulingRecord, len(records))
+	for i := range records {
+		latest[records[i].TaskID] = &records[i]
+	}
+
+	view := &WorkflowRunView{Run: *run, Status: schedulingrecord.FINISHED, Nodes: make([]NodeStatus, 0, len(nodes))}
+	for _, n := range nodes {
+		s := NodeStatus{WorkflowNode: n, Status: waitingStatus}
+		if r, ok := latest[n.TaskID]; ok {
+			s.Status, s.RecordID, s.Attempt, s.Message = r.Status, r.ID, r.Attempt, r.Message
+		}
+		switch s.Status {
+		case schedulingrecord.FINISHED, schedulingrecord.SKIPPED:
+		case schedulingrecord.FAILED, schedulingrecord.TIMEOUT:
+			if view.Status != schedulingrecord.RUNNING {
+				view.Status = schedulingrecord.FAILED
+			}
+		default:
+			view.Status = schedulingrecord.RUNNING
+		}
+		view.Nodes = append(view.Nodes, s)
+	}
+	return view, nil
+}
+
+// getWorkflowNodes returns the nodes of workflow, every node follows its
+// upstreams.
+func getWorkflowNodes(id uint64) ([]WorkflowNode, *commons.Error) {
+	tasks, err := task.GetByWorkflow(id)
+	if err != nil {
+		log.WithField("id", id).Errorf("occurred exception when getting tasks of workflow: %v", err)
+		return nil, commons.StatusDBOperationAbnormal
+	}
+	graph := make(map[uint64][]uint64, len(tasks))
+	byID := make(map[uint64]*task.Task, len(tasks))
+	for i := range tasks {
+		t := &tasks[i]
+		byID[t.ID] = t
+		graph[t.ID] = nil
+	}
+	for _, t := range tasks {
+		// the deleted upstreams are left out.
+		for _, up := range t.Upstreams {
+			if _, ok := byID[up]; ok {
+				graph[t.ID] = append(graph[t.ID], up)
+			}
+		}
+	}
+	sorted, err := utils.TopoSort(graph)
+	if err != nil {
+		log.WithField("id", id).Errorf("occurred exception when sorting nodes of workflow: %v", err)
+		return nil, &commons.Error{Code: http.StatusInternalServerError, Error: err}
+	}
+	nodes := make([]WorkflowNode, 0, len(sorted))
+	for _, tid := range sorted {
+		t := byID[tid]
+		nodes = append(nodes, WorkflowNode{TaskID: t.ID, Name: t.Name, Upstreams: t.Upstreams})
+	}
+	return nodes, nil
+}
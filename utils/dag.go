@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TopoSort returns the nodes of graph, node -> upstream nodes, in the order
+// that every node follows its upstreams, the smaller id first if they are
+// independent. Returns error if an upstream is not a node of graph or there
+// is a cycle.
+func TopoSort(graph map[uint64][]uint64) ([]uint64, error) {
+	pending := make(map[uint64]int, len(graph))
+	downstreams := make(map[uint64][]uint64, len(graph))
+	for node, upstreams := range graph {
+		for _, up := range upstreams {
+			if _, ok := graph[up]; !ok {
+				return nil, fmt.Errorf("upstream %d of %d is unknown", up, node)
+			}
+			if up == node {
+				return nil, fmt.Errorf("cycle detected: %d -> %d", node, node)
+			}
+			pending[node]++
+			downstreams[up] = append(downstreams[up], node)
+		}
+	}
+
+	var ready, sorted []uint64
+	for node := range graph {
+		if pending[node] == 0 {
+			ready = append(ready, node)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return ready[i] < ready[j] })
+		node := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, node)
+		for _, down := range downstreams[node] {
+			if pending[down]--; pending[down] == 0 {
+				ready = append(ready, down)
+			}
+		}
+	}
+	if len(sorted) < len(graph) {
+		return nil, fmt.Errorf("cycle detected: %v", cycle(graph, pending))
+	}
+	return sorted, nil
+}
+
+// cycle returns a cycle of the nodes which are still pending, every one of
+// them has a pending upstream.
+func cycle(graph map[uint64][]uint64, pending map[uint64]int) string {
+	var start uint64
+	for node, n := range pending {
+		if n > 0 && (start == 0 || node < start) {
+			start = node
+		}
+	}
+	// walks the pending upstreams until a node is visited twice.
+	visited := make(map[uint64]int)
+	var path []uint64
+	for node := start; ; {
+		if i, ok := visited[node]; ok {
+			path = path[i:]
+			break
+		}
+		visited[node] = len(path)
+		path = append(path, node)
+		for _, up := range graph[node] {
+			if pending[up] > 0 {
+				node = up
+				break
+			}
+		}
+	}
+	// prints in the order of execution, which is reversed to the walking.
+	s := fmt.Sprint(path[0])
+	for i := len(path) - 1; i >= 0; i-- {
+		s += fmt.Sprintf(" -> %d", path[i])
+	}
+	return s
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopoSort(t *testing.T) {
+	sorted, err := TopoSort(map[uint64][]uint64{
+		4: {2, 3},
+		1: nil,
+		3: {1},
+		2: {1},
+		5: nil,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, sorted)
+
+	sorted, err = TopoSort(map[uint64][]uint64{})
+	assert.Nil(t, err)
+	assert.Empty(t, sorted)
+}
+
+func TestTopoSortInvalid(t *testing.T) {
+	_, err := TopoSort(map[uint64][]uint64{1: {1}})
+	assert.EqualError(t, err, "cycle detected: 1 -> 1")
+
+	_, err = TopoSort(map[uint64][]uint64{1: nil, 2: {1, 4}, 3: {2}, 4: {3}})
+	assert.EqualError(t, err, "cycle detected: 2 -> 3 -> 4 -> 2")
+
+	_, err = TopoSort(map[uint64][]uint64{1: nil, 2: {9}})
+	assert.EqualError(t, err, "upstream 9 of 2 is unknown")
+}
@@ -0,0 +1,329 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxICSOccurrences the max number of occurrences expanded from a recurring
+// event.
+const maxICSOccurrences = 1000
+
+// icsFrequencies the steps of the FREQ of RRULE in years, months and days.
+var icsFrequencies = map[string][3]int{
+	"YEARLY":  {1, 0, 0},
+	"MONTHLY": {0, 1, 0},
+	"WEEKLY":  {0, 0, 7},
+	"DAILY":   {0, 0, 1},
+}
+
+// icsDuration matches the DURATION of iCalendar, e.g. P1D, PT1H30M, P1W.
+var icsDuration = regexp.MustCompile(`^\+?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// ICSEvent an event of iCalendar, it lasts [Start, End). The Start and End of
+// all-day event are the midnights in the location of parsing.
+type ICSEvent struct {
+	Summary string
+	Start   time.Time
+	End     time.Time
+	AllDay  bool
+}
+
+// icsRule the RRULE of a recurring event, it repeats by step until the
+// count or until is reached.
+type icsRule struct {
+	step  [3]int
+	count int
+	until time.Time
+}
+
+// icsProperty a content line of iCalendar, e.g. DTSTART;TZID=Asia/Shanghai:20211001T090000.
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// ParseICS returns the events of iCalendar data, the floating times and
+// dates are in loc. The recurring events are expanded to their occurrences
+// in [from, to), the ones by RDATE or the rule parts other than FREQ,
+// INTERVAL, COUNT and UNTIL are ignored and counted, so are the cancelled and
+// zero-length events.
+func ParseICS(data []byte, loc *time.Location, from, to time.Time) ([]ICSEvent, int, error) {
+	var (
+		events  []ICSEvent
+		ignored int
+		// the components which are open, the innermost last.
+		stack   []string
+		event   map[string]icsProperty
+		exdates []icsProperty
+		found   bool
+	)
+	for i, line := range unfoldICS(string(data)) {
+		if line == "" {
+			continue
+		}
+		p, err := parseICSLine(line)
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		switch p.name {
+		case "BEGIN":
+			component := strings.ToUpper(p.value)
+			found = found || component == "VCALENDAR"
+			stack = append(stack, component)
+			if component == "VEVENT" {
+				event, exdates = make(map[string]icsProperty), nil
+			}
+			continue
+		case "END":
+			component := strings.ToUpper(p.value)
+			if len(stack) == 0 || stack[len(stack)-1] != component {
+				return nil, 0, fmt.Errorf("line %d: unexpected END:%s", i+1, p.value)
+			}
+			stack = stack[:len(stack)-1]
+			if component != "VEVENT" {
+				continue
+			}
+			occurrences, err := icsEvents(event, exdates, loc, from, to)
+			if err != nil {
+				return nil, 0, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			if len(occurrences) > 0 {
+				events = append(events, occurrences...)
+			} else {
+				ignored++
+			}
+			event, exdates = nil, nil
+			continue
+		}
+		if len(stack) > 0 && stack[len(stack)-1] == "VEVENT" {
+			if p.name == "EXDATE" {
+				// it may occur more than once.
+				exdates = append(exdates, p)
+			} else {
+				event[p.name] = p
+			}
+		}
+	}
+	if !found {
+		return nil, 0, errors.New("BEGIN:VCALENDAR is absent")
+	}
+	if len(stack) > 0 {
+		return nil, 0, fmt.Errorf("END:%s is absent", stack[len(stack)-1])
+	}
+	return events, ignored, nil
+}
+
+// unfoldICS returns the content lines, the folded ones which continue with a
+// space or tab are joined.
+func unfoldICS(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	data = strings.ReplaceAll(data, "\n\t", "")
+	return strings.Split(data, "\n")
+}
+
+// parseICSLine parses the name, params and value of a content line, the name
+// and param names are upper cased.
+func parseICSLine(line string) (icsProperty, error) {
+	quoted := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return icsProperty{}, fmt.Errorf("invalid content line %q", line)
+	}
+	parts := strings.Split(line[:colon], ";")
+	p := icsProperty{name: strings.ToUpper(parts[0]), params: make(map[string]string), value: line[colon+1:]}
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 2 {
+			p.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return p, nil
+}
+
+// icsEvents builds the event by its properties, the recurring one is
+// expanded to its occurrences in [from, to) except the EXDATE ones. Returns
+// none if it should be ignored.
+func icsEvents(props map[string]icsProperty, exdates []icsProperty, loc *time.Location, from, to time.Time) ([]ICSEvent, error) {
+	if _, rdate := props["RDATE"]; rdate || strings.EqualFold(props["STATUS"].value, "CANCELLED") {
+		return nil, nil
+	}
+	dtstart, ok := props["DTSTART"]
+	if !ok {
+		return nil, errors.New("DTSTART of event is absent")
+	}
+	start, allDay, err := parseICSTime(dtstart, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	end := start
+	if dtend, ok := props["DTEND"]; ok {
+		if end, _, err = parseICSTime(dtend, loc); err != nil {
+			return nil, err
+		}
+	} else if d, ok := props["DURATION"]; ok {
+		if end, err = addICSDuration(start, d.value); err != nil {
+			return nil, err
+		}
+	} else if allDay {
+		end = start.AddDate(0, 0, 1)
+	}
+	if !end.After(start) {
+		return nil, nil
+	}
+	e := ICSEvent{Summary: unescapeICS(props["SUMMARY"].value), Start: start, End: end, AllDay: allDay}
+	rrule, ok := props["RRULE"]
+	if !ok {
+		return []ICSEvent{e}, nil
+	}
+	r, ok, err := parseICSRule(rrule.value, loc)
+	if err != nil || !ok {
+		return nil, err
+	}
+	excluded := make(map[int64]bool)
+	for _, p := range exdates {
+		for _, v := range strings.Split(p.value, ",") {
+			t, _, err := parseICSTime(icsProperty{name: p.name, params: p.params, value: v}, loc)
+			if err != nil {
+				return nil, err
+			}
+			excluded[t.UnixNano()] = true
+		}
+	}
+	return expandICS(e, r, excluded, from, to), nil
+}
+
+// parseICSRule parses the RRULE, returns false if it has the parts which
+// can't be expanded, e.g. BYDAY.
+func parseICSRule(value string, loc *time.Location) (icsRule, bool, error) {
+	var r icsRule
+	interval := 1
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return r, false, fmt.Errorf("invalid RRULE %q", value)
+		}
+		var err error
+		switch v := strings.ToUpper(kv[1]); strings.ToUpper(kv[0]) {
+		case "FREQ":
+			step, ok := icsFrequencies[v]
+			if !ok {
+				return r, false, nil
+			}
+			r.step = step
+		case "INTERVAL":
+			if interval, err = strconv.Atoi(v); err != nil || interval < 1 {
+				return r, false, fmt.Errorf("invalid INTERVAL %q of RRULE", kv[1])
+			}
+		case "COUNT":
+			if r.count, err = strconv.Atoi(v); err != nil || r.count < 1 {
+				return r, false, fmt.Errorf("invalid COUNT %q of RRULE", kv[1])
+			}
+		case "UNTIL":
+			if r.until, _, err = parseICSTime(icsProperty{name: "UNTIL", value: v}, loc); err != nil {
+				return r, false, err
+			}
+		case "WKST":
+			// only matters with BYWEEKNO or BYDAY.
+		default:
+			return r, false, nil
+		}
+	}
+	if r.step == [3]int{} {
+		return r, false, fmt.Errorf("FREQ of RRULE %q is absent", value)
+	}
+	for i := range r.step {
+		r.step[i] *= interval
+	}
+	return r, true, nil
+}
+
+// expandICS returns the occurrences of recurring event in [from, to), at
+// most maxICSOccurrences. They are repeated on the wall clock, and the ones
+// on the dates which don't exist are skipped, e.g. February 30.
+func expandICS(e ICSEvent, r icsRule, excluded map[int64]bool, from, to time.Time) []ICSEvent {
+	var events []ICSEvent
+	for i, n := 0, 0; len(events) < maxICSOccurrences; i++ {
+		years, months, days := i*r.step[0], i*r.step[1], i*r.step[2]
+		start := e.Start.AddDate(years, months, days)
+		if !start.Before(to) || (!r.until.IsZero() && start.After(r.until)) {
+			break
+		}
+		if start.Day() != e.Start.Day() && r.step[2] == 0 {
+			continue
+		}
+		if n++; r.count > 0 && n > r.count {
+			break
+		}
+		end := e.End.AddDate(years, months, days)
+		if excluded[start.UnixNano()] || !end.After(from) {
+			continue
+		}
+		events = append(events, ICSEvent{Summary: e.Summary, Start: start, End: end, AllDay: e.AllDay})
+	}
+	return events
+}
+
+// parseICSTime parses the DATE or DATE-TIME value, returns true if it's a
+// DATE. The time is in UTC if it ends with Z, or in the TZID param, otherwise
+// it's floating in loc.
+func parseICSTime(p icsProperty, loc *time.Location) (time.Time, bool, error) {
+	if strings.EqualFold(p.params["VALUE"], "DATE") || len(p.value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", p.value, loc)
+		if err != nil {
+			return t, true, fmt.Errorf("invalid %s %q", p.name, p.value)
+		}
+		return t, true, nil
+	}
+	if strings.HasSuffix(p.value, "Z") {
+		loc = time.UTC
+	} else if tzid := p.params["TZID"]; tzid != "" {
+		l, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown TZID %q of %s", tzid, p.name)
+		}
+		loc = l
+	}
+	t, err := time.ParseInLocation("20060102T150405", strings.TrimSuffix(p.value, "Z"), loc)
+	if err != nil {
+		return t, false, fmt.Errorf("invalid %s %q", p.name, p.value)
+	}
+	return t, false, nil
+}
+
+// addICSDuration returns t plus the DURATION, the weeks and days are added
+// on the wall clock.
+func addICSDuration(t time.Time, value string) (time.Time, error) {
+	m := icsDuration.FindStringSubmatch(value)
+	if m == nil || value == "P" || value == "+P" {
+		return t, fmt.Errorf("invalid DURATION %q", value)
+	}
+	n := make([]int, len(m))
+	for i, v := range m[1:] {
+		if v != "" {
+			n[i+1], _ = strconv.Atoi(v)
+		}
+	}
+	clock := time.Duration(n[3])*time.Hour + time.Duration(n[4])*time.Minute + time.Duration(n[5])*time.Second
+	return t.AddDate(0, 0, n[1]*7+n[2]).Add(clock), nil
+}
+
+// unescapeICS unescapes the TEXT value, e.g. the SUMMARY.
+func unescapeICS(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseICS(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	assert.Nil(t, err)
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20211001",
+		"DTEND;VALUE=DATE:20211008",
+		"SUMMARY:National Day\\, Golden Week",
+		"BEGIN:VALARM",
+		"DTSTART:20210930T090000",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20211224T220000Z",
+		"DURATION:PT2H30M",
+		"SUMMARY:Database mainte",
+		" nance",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;TZID=America/New_York:20211231T230000",
+		"DTEND;TZID=America/New_York:20220101T010000",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20220101",
+		"RRULE:FREQ=YEARLY",
+		"SUMMARY:New Year",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20220102T100000",
+		"SUMMARY:Reminder",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	from := time.Date(2021, 1, 1, 0, 0, 0, 0, shanghai)
+	events, ignored, err := ParseICS([]byte(data), shanghai, from, from.AddDate(3, 0, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, ignored, "zero-length")
+	assert.Len(t, events, 5)
+
+	assert.True(t, events[0].AllDay)
+	assert.Equal(t, "National Day, Golden Week", events[0].Summary)
+	assert.Equal(t, time.Date(2021, 10, 1, 0, 0, 0, 0, shanghai), events[0].Start)
+	assert.Equal(t, time.Date(2021, 10, 8, 0, 0, 0, 0, shanghai), events[0].End)
+
+	assert.False(t, events[1].AllDay)
+	assert.Equal(t, "Database maintenance", events[1].Summary)
+	assert.Equal(t, time.Date(2021, 12, 25, 0, 30, 0, 0, time.UTC), events[1].End)
+
+	assert.True(t, events[2].Start.Equal(time.Date(2022, 1, 1, 4, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 2*time.Hour, events[2].End.Sub(events[2].Start))
+
+	for i, year := range []int{2022, 2023} {
+		e := events[3+i]
+		assert.True(t, e.AllDay)
+		assert.Equal(t, "New Year", e.Summary)
+		assert.Equal(t, time.Date(year, 1, 1, 0, 0, 0, 0, shanghai), e.Start)
+		assert.Equal(t, time.Date(year, 1, 2, 0, 0, 0, 0, shanghai), e.End)
+	}
+}
+
+func TestParseICSRecurring(t *testing.T) {
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"DTSTART:20211001T220000",
+		"DURATION:PT2H",
+		"RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=3",
+		"SUMMARY:Biweekly",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20211230",
+		"RRULE:FREQ=DAILY;UNTIL=20220102",
+		"EXDATE;VALUE=DATE:20211231",
+		"EXDATE;VALUE=DATE:20220101",
+		"SUMMARY:Daily",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20210131T000000Z",
+		"DTEND:20210131T010000Z",
+		"RRULE:FREQ=MONTHLY;COUNT=3",
+		"SUMMARY:Monthly",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20211001T000000Z",
+		"DTEND:20211001T010000Z",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,FR",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20200101T000000Z",
+		"DTEND:20200101T010000Z",
+		"RRULE:FREQ=YEARLY;COUNT=2",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\n")
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	events, ignored, err := ParseICS([]byte(data), time.UTC, from, from.AddDate(1, 0, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, ignored, "BYDAY and the one out of the window")
+
+	var starts []string
+	for _, e := range events {
+		starts = append(starts, e.Summary+" "+e.Start.Format(time.RFC3339))
+	}
+	assert.Equal(t, []string{
+		"Biweekly 2021-10-01T22:00:00Z",
+		"Biweekly 2021-10-15T22:00:00Z",
+		"Biweekly 2021-10-29T22:00:00Z",
+		"Daily 2021-12-30T00:00:00Z",
+		"Daily 2022-01-02T00:00:00Z",
+		// the 3 occurrences are in January, March and May, the former is
+		// before the window.
+		"Monthly 2021-03-31T00:00:00Z",
+		"Monthly 2021-05-31T00:00:00Z",
+	}, starts)
+	assert.Equal(t, 2*time.Hour, events[0].End.Sub(events[0].Start))
+}
+
+func TestParseICSInvalid(t *testing.T) {
+	cases := map[string]string{
+		"BEGIN:VEVENT\nDTSTART:20211001T000000Z\nEND:VEVENT":                                               "BEGIN:VCALENDAR is absent",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20211001T000000Z\nEND:VEVENT":                              "END:VCALENDAR is absent",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:no start\nEND:VEVENT\nEND:VCALENDAR":                       "line 4: DTSTART of event is absent",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;TZID=Mars/Base:20211001T000000\nEND:VEVENT\nEND:VCALENDAR": `line 4: unknown TZID "Mars/Base" of DTSTART`,
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20211001T000000Z\nDURATION:P\nEND:VEVENT\nEND:VCALENDAR":   `line 5: invalid DURATION "P"`,
+		"BEGIN:VCALENDAR\ngarbage\nEND:VCALENDAR":                                                          `line 2: invalid content line "garbage"`,
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20211001\nRRULE:COUNT=2\nEND:VEVENT\nEND:VCALENDAR":        `line 5: FREQ of RRULE "COUNT=2" is absent`,
+	}
+	for data, expected := range cases {
+		_, _, err := ParseICS([]byte(data), time.UTC, time.Time{}, time.Now())
+		assert.EqualError(t, err, expected, data)
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// MergeJSON merges patch into doc like JSON Merge Patch (RFC 7386): objects
+// are merged recursively, null removes the member and others replace it.
+func MergeJSON(doc, patch []byte) ([]byte, error) {
+	if len(patch) == 0 {
+		return doc, nil
+	}
+	var d, p interface{}
+	if len(doc) > 0 {
+		if err := decodeJSON(doc, &d); err != nil {
+			return nil, err
+		}
+	}
+	if err := decodeJSON(patch, &p); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeValue(d, p))
+}
+
+// decodeJSON decodes data into v, the numbers are kept as json.Number so that
+// the integers above 2^53 aren't rounded by float64.
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("invalid character after top-level value")
+	}
+	return nil
+}
+
+func mergeValue(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		d = make(map[string]interface{}, len(p))
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+			continue
+		}
+		d[k] = mergeValue(d[k], v)
+	}
+	return d
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeJSON(t *testing.T) {
+	cases := []struct {
+		doc, patch, want string
+	}{
+		{`{"url":"a","body":{"id":1,"name":"x"}}`, `{"body":{"id":2}}`, `{"url":"a","body":{"id":2,"name":"x"}}`},
+		{`{"url":"a","body":{"id":1}}`, `{"body":null}`, `{"url":"a"}`},
+		{`{"body":[1,2]}`, `{"body":[3]}`, `{"body":[3]}`},
+		{`{"body":"raw"}`, `{"body":{"id":1}}`, `{"body":{"id":1}}`},
+		{``, `{"url":"a"}`, `{"url":"a"}`},
+		{`{"url":"a"}`, ``, `{"url":"a"}`},
+	}
+	for _, c := range cases {
+		got, err := MergeJSON([]byte(c.doc), []byte(c.patch))
+		assert.Nil(t, err)
+		assert.JSONEq(t, c.want, string(got), "merge %s into %s", c.patch, c.doc)
+	}
+
+	got, err := MergeJSON([]byte(`{"body":{"id":1,"name":"x"}}`), []byte(`{"body":{"id":1234567890123456789}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"body":{"id":1234567890123456789,"name":"x"}}`, string(got), "integers above 2^53 should be kept")
+
+	_, err = MergeJSON([]byte(`{"url":"a"}`), []byte(`{`))
+	assert.NotNil(t, err)
+	_, err = MergeJSON([]byte(`{"url":"a"}`), []byte(`{} {}`))
+	assert.NotNil(t, err)
+}
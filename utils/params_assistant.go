@@ -58,3 +58,29 @@ func GetUint64OrPanic(c *gin.Context, key string, def uint64) uint64 {
 	}
 	return pv
 }
+
+// GetQueryIntOrDefault return parse int value of query, otherwise return def.
+func GetQueryIntOrDefault(c *gin.Context, key string, def int) int {
+	v := c.Query(key)
+	if v == "" {
+		return def
+	}
+	pv, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		return def
+	}
+	return int(pv)
+}
+
+// GetQueryUint64OrDefault return parse uint64 value of query, otherwise return def.
+func GetQueryUint64OrDefault(c *gin.Context, key string, def uint64) uint64 {
+	v := c.Query(key)
+	if v == "" {
+		return def
+	}
+	pv, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return def
+	}
+	return pv
+}